@@ -0,0 +1,796 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const (
+	// accessibleLines is how much narration the accessible view shows when
+	// the terminal didn't say how tall it is
+	accessibleLines = 20
+	// accessibleHistory is how much narration is kept for the view
+	accessibleHistory = 200
+)
+
+// pieceNames are how pieces are read out
+var pieceNames = map[PieceType]string{
+	Pawn:   "pawn",
+	Knight: "knight",
+	Bishop: "bishop",
+	Rook:   "rook",
+	Queen:  "queen",
+	King:   "king",
+}
+
+// pieceOrder is the order a side's pieces are read out in
+var pieceOrder = []PieceType{King, Queen, Rook, Bishop, Knight, Pawn}
+
+// accessibleHelp lists the commands of the accessible mode
+var accessibleHelp = []string{
+	"In the lobby: who lists players, seeks and games. seek [5+3] [variant] looks for a game, play <player> accepts their seek, bot [5+3] [variant] plays the bot.",
+	"challenge <user> [5+3] [white|black] [variant] [casual], room [options], join <code> and watch <user|code> work as on the command line. cancel withdraws a seek, challenge or room.",
+	"In a game, type a move like Nf3, exd5, O-O or g1f3; moves typed during the opponent's turn are premoves, played as soon as they can be, and cancel drops them. resign, draw, accept, decline, rematch, say <message>, mute, and leave to go back to the lobby.",
+	"To read the board: board, rank <1-8>, file <a-h>, square <e4>, where <piece> as in where black knight, moves, last and clock.",
+	"alerts bell, title or desktop tell you when it's your move while you're in another window. help repeats this and quit leaves.",
+}
+
+// runAccessible plays over a session without a terminal, reading a command
+// per line and writing what happens as plain lines of text
+func runAccessible(s ssh.Session, args []string) {
+	m := sessionModel(s)
+	m.accessible = true
+	m = m.startAccessible(args)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(s)
+		for scanner.Scan() {
+			select {
+			case lines <- strings.TrimRight(scanner.Text(), "\r"):
+			case <-s.Context().Done():
+				return
+			}
+		}
+	}()
+
+	flush := func() {
+		for _, line := range m.spoken {
+			wish.Println(s, line)
+		}
+		m.spoken = nil
+	}
+	for {
+		flush()
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			var quit bool
+			if m, quit = m.accessibleCommand(line); quit {
+				flush()
+				return
+			}
+		case update, ok := <-m.player.UpdateChan:
+			if !ok {
+				return
+			}
+			next, _ := m.handleGameUpdate(update)
+			m = next.(model)
+		case <-s.Context().Done():
+			return
+		}
+	}
+}
+
+// startAccessible greets the player and runs the command they connected
+// with, if any
+func (m model) startAccessible(args []string) model {
+	m = m.speak(fmt.Sprintf("Welcome to CheSSH, %s. You're in the lobby; type help for commands.", m.player.Name))
+	if len(args) > 0 {
+		m, _ = m.accessibleCommand(strings.Join(args, " "))
+	}
+	return m
+}
+
+// speak adds lines to the narration
+func (m model) speak(lines ...string) model {
+	m.spoken = append(m.spoken, lines...)
+	if len(m.spoken) > accessibleHistory {
+		m.spoken = m.spoken[len(m.spoken)-accessibleHistory:]
+	}
+	return m
+}
+
+// accessibleView is the latest narration, wrapped to the terminal, above
+// the command line
+func (m model) accessibleView() string {
+	var lines []string
+	for _, line := range m.spoken {
+		if m.width > 0 {
+			line = lipgloss.NewStyle().Width(m.width).Render(line)
+		}
+		lines = append(lines, strings.Split(line, "\n")...)
+	}
+	room := accessibleLines
+	if m.height > 0 {
+		room = m.height - 1
+	}
+	if len(lines) > room {
+		lines = lines[len(lines)-room:]
+	}
+	return strings.Join(append(lines, "> "+m.input), "\n")
+}
+
+// handleAccessibleKey edits the command line of the accessible view
+func (m model) handleAccessibleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEscape:
+		m.input = ""
+	case tea.KeyBackspace:
+		if r := []rune(m.input); len(r) > 0 {
+			m.input = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.input += " "
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	case tea.KeyEnter:
+		line := m.input
+		m.input = ""
+		m = m.speak("> " + line)
+		next, quit := m.accessibleCommand(line)
+		if quit {
+			return next, tea.Quit
+		}
+		return next, nil
+	}
+	return m, nil
+}
+
+// accessibleCommand runs a line typed in the accessible mode, reporting
+// whether the player asked to quit
+func (m model) accessibleCommand(line string) (model, bool) {
+	m.notice = ""
+	before := m
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return m, false
+	}
+	command, args := strings.ToLower(fields[0]), fields[1:]
+
+	if m.confirmResign {
+		m.confirmResign = false
+		if command == "yes" || command == "y" {
+			if err := m.gameSession.Resign(m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+		} else {
+			m.notice = "You didn't resign."
+		}
+		return m.narrate(before), false
+	}
+
+	switch command {
+	case "quit", "exit":
+		return m.speak("Goodbye."), true
+	case "help", "?":
+		m = m.speak(accessibleHelp...)
+
+	// Reading the board
+	case "board", "position":
+		m = m.speak(describePieces(m.game, White), describePieces(m.game, Black), m.turnStatus())
+	case "rank":
+		rank := strings.Join(args, "")
+		if len(rank) != 1 || rank[0] < '1' || rank[0] > '8' {
+			m.notice = "Which rank? Type rank and a number from 1 to 8."
+			break
+		}
+		var squares []Position
+		for col := range 8 {
+			squares = append(squares, Position{int(rank[0] - '1'), col})
+		}
+		m = m.speak(fmt.Sprintf("Rank %s: %s.", rank, describeSquares(m.game, squares)))
+	case "file":
+		file := strings.ToLower(strings.Join(args, ""))
+		if len(file) != 1 || file[0] < 'a' || file[0] > 'h' {
+			m.notice = "Which file? Type file and a letter from a to h."
+			break
+		}
+		var squares []Position
+		for row := range 8 {
+			squares = append(squares, Position{row, int(file[0] - 'a')})
+		}
+		m = m.speak(fmt.Sprintf("File %s: %s.", file, describeSquares(m.game, squares)))
+	case "square":
+		pos, ok := parseSquare(strings.Join(args, ""))
+		if !ok {
+			m.notice = "Which square? Type square and a square like e4."
+			break
+		}
+		m = m.speak(describeSquare(m.game, pos) + ".")
+	case "where":
+		m = m.where(args)
+	case "moves":
+		m = m.speak(describeMoveList(m.game))
+	case "last":
+		if len(m.game.MoveHistory) == 0 {
+			m.notice = "No moves yet."
+			break
+		}
+		m = m.speak(describeMove(m.game.MoveHistory[len(m.game.MoveHistory)-1]) + ".")
+	case "clock", "time":
+		m = m.speak(m.describeClock())
+
+	// The lobby
+	case "who", "lobby":
+		m = m.speak(describeLobby(GetGameManager().Lobby(), m.player)...)
+	case "seek":
+		if m.gameState != "lobby" {
+			m.notice = "Go back to the lobby first."
+			break
+		}
+		m = m.seek(args)
+	case "play":
+		if m.gameState != "lobby" {
+			m.notice = "Go back to the lobby first."
+			break
+		}
+		m = m.acceptSeekFrom(strings.Join(args, " "))
+	case "bot":
+		if m.gameState != "lobby" {
+			m.notice = "Go back to the lobby first."
+			break
+		}
+		m = m.playBot(args)
+	case "challenge", "room", "join", "watch":
+		if m.gameState == "playing" {
+			m.notice = "Finish this game first."
+			break
+		}
+		switch command {
+		case "challenge":
+			m = m.sendChallenge(args)
+		case "room":
+			m = m.createRoom(args)
+		case "join":
+			m = m.joinRoom(strings.Join(args, ""))
+		case "watch":
+			if m.gameState == "spectating" {
+				m = m.stopWatching()
+			}
+			m, _ = m.watch(strings.Join(args, " "))
+		}
+	case "cancel":
+		switch {
+		case m.gameState == "waiting":
+			GetGameManager().RemoveFromQueue(m.player.ID)
+			m = m.enterLobby()
+		case len(m.premoves) > 0:
+			m = m.cancelPremoves()
+			m.notice = "Premoves cancelled."
+		case m.outgoing != nil:
+			GetGameManager().CancelChallenge(m.outgoing.ID, m.player.ID)
+			m.outgoing = nil
+			m.notice = "Challenge withdrawn."
+		case m.room != nil:
+			GetGameManager().CloseRoom(m.player.ID)
+			m.room = nil
+			m.notice = "Room closed."
+		default:
+			m.notice = "There's nothing to cancel."
+		}
+	case "leave":
+		switch m.gameState {
+		case "spectating":
+			m = m.stopWatching()
+		case "waiting":
+			GetGameManager().RemoveFromQueue(m.player.ID)
+			m = m.enterLobby()
+		case "finished", "opponent_disconnected":
+			GetGameManager().LeaveGame(m.player.ID)
+			m = m.enterLobby()
+		case "playing":
+			if !m.gameSession.TimeControl.Correspondence() {
+				m.notice = "Finish or resign this game first."
+				break
+			}
+			GetGameManager().LeaveGame(m.player.ID)
+			m = m.enterLobby()
+		default:
+			m.notice = "You're already in the lobby."
+		}
+
+	// A game
+	case "resign":
+		if m.gameState != "playing" {
+			m.notice = "You're not playing a game."
+			break
+		}
+		m.confirmResign = true
+		m = m.speak("Resign this game? Type yes to confirm.")
+	case "draw":
+		if m.gameState != "playing" {
+			m.notice = "You're not playing a game."
+			break
+		}
+		if err := m.gameSession.OfferDraw(m.player.ID); err != nil {
+			m.notice = err.Error()
+		} else if m.drawOffer != "received" {
+			m.notice = "Draw offered."
+		}
+	case "accept", "yes":
+		switch {
+		case m.gameState == "playing" && m.drawOffer == "received":
+			if err := m.gameSession.AcceptDraw(m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+			m.drawOffer = ""
+		case m.gameState == "finished" && m.rematch == "received":
+			if err := GetGameManager().OfferRematch(m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+		case len(m.challenges) > 0 && m.gameState != "playing":
+			challenge := m.challenges[0]
+			m.challenges = m.challenges[1:]
+			if err := GetGameManager().AcceptChallenge(challenge.ID, m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+		default:
+			m.notice = "There's nothing to accept."
+		}
+	case "decline", "no":
+		switch {
+		case m.gameState == "playing" && m.drawOffer == "received":
+			m.gameSession.DeclineDraw(m.player.ID)
+			m.drawOffer = ""
+			m.notice = "Draw declined."
+		case m.gameState == "finished" && m.rematch == "received":
+			m.gameSession.DeclineRematch(m.player.ID)
+			m.rematch = ""
+			m.notice = "Rematch declined."
+		case len(m.challenges) > 0 && m.gameState != "playing":
+			GetGameManager().DeclineChallenge(m.challenges[0].ID, m.player.ID)
+			m.challenges = m.challenges[1:]
+			m.notice = "Challenge declined."
+		default:
+			m.notice = "There's nothing to decline."
+		}
+	case "rematch":
+		if m.gameState != "finished" {
+			m.notice = "Rematches are offered once a game is over."
+			break
+		}
+		if err := GetGameManager().OfferRematch(m.player.ID); err != nil {
+			m.notice = err.Error()
+		} else if m.rematch != "received" {
+			m.rematch = "sent"
+			m.notice = "Rematch offered."
+		}
+	case "say":
+		if m.gameSession == nil {
+			m.notice = "You can only chat during a game."
+			break
+		}
+		if err := m.gameSession.Say(m.player, strings.Join(args, " ")); err != nil {
+			m.notice = err.Error()
+		}
+	case "alerts":
+		if len(args) == 0 {
+			var choices []string
+			for _, alert := range alerts {
+				choices = append(choices, fmt.Sprintf("%s to %s", alert.name, alert.description))
+			}
+			m = m.speak(fmt.Sprintf("Alerts when it's your move: %s. Type alerts and any of %s, or alerts none.",
+				m.alertsSummary(), strings.Join(choices, "; ")))
+			break
+		}
+		m = m.setAlerts(strings.Join(args, " "))
+	case "mute":
+		m.chatMuted = !m.chatMuted
+		m.notice = "Chat unmuted."
+		if m.chatMuted {
+			m.notice = "Chat muted."
+		}
+
+	default:
+		m = m.typedMove(line)
+	}
+	return m.narrate(before), false
+}
+
+// typedMove plays a move typed in algebraic notation or as coordinates
+func (m model) typedMove(text string) model {
+	switch {
+	case m.gameState != "playing":
+		m.notice = fmt.Sprintf("Unknown command %q; type help for the commands.", strings.TrimSpace(text))
+	case m.result != nil:
+		m.notice = "The game is over."
+	case !m.isMyTurn:
+		// Moves typed while the opponent thinks are premoves
+		from, to, err := m.premoveGame().parseMove(text)
+		if err != nil {
+			m.notice = err.Error()
+			return m
+		}
+		m = m.queuePremove(from, to)
+	default:
+		from, to, err := m.game.parseMove(text)
+		if err != nil {
+			m.notice = err.Error()
+			return m
+		}
+		if !m.gameSession.MakeMove(m.player.ID, from, to) {
+			m.notice = fmt.Sprintf("%s can't be played now.", strings.TrimSpace(text))
+			return m
+		}
+		m.isMyTurn = false
+		m.selected = nil
+		m.validMoves = make([]Position, 0)
+	}
+	return m
+}
+
+// acceptSeekFrom starts a game against a player waiting in the queue
+func (m model) acceptSeekFrom(name string) model {
+	for _, seek := range GetGameManager().Lobby().Seeks {
+		if seek.Player.ID != m.player.ID && (strings.EqualFold(seek.Player.Name, name) || strings.EqualFold(seek.Player.Identity, name)) {
+			if err := GetGameManager().AcceptSeek(seek.Player.ID, m.player); err != nil {
+				m.notice = err.Error()
+			}
+			return m
+		}
+	}
+	m.notice = fmt.Sprintf("%s isn't looking for a game; type who to see who is.", name)
+	return m
+}
+
+// where reads out where pieces of a kind stand, e.g. "where black knight"
+func (m model) where(args []string) model {
+	colors := []Color{White, Black}
+	kind := Empty
+	for _, word := range args {
+		word = strings.ToLower(word)
+		switch word {
+		case "white":
+			colors = []Color{White}
+		case "black":
+			colors = []Color{Black}
+		case "my":
+			if m.gameState == "playing" {
+				colors = []Color{m.player.Color}
+			}
+		}
+		for t, name := range pieceNames {
+			if word == name || word == name+"s" || strings.EqualFold(word, pieceLetters[t]) || (t == Pawn && word == "p") {
+				kind = t
+			}
+		}
+	}
+	if kind == Empty {
+		m.notice = "Where is what? Try where knight, or where black queen."
+		return m
+	}
+
+	var lines []string
+	for _, color := range colors {
+		squares := pieceSquares(m.game, Piece{kind, color})
+		if len(squares) == 0 {
+			lines = append(lines, fmt.Sprintf("%s has no %ss.", color, pieceNames[kind]))
+			continue
+		}
+		name := pieceNames[kind]
+		if len(squares) > 1 {
+			name += "s"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s.", color, name, listJoin(squares)))
+	}
+	return m.speak(lines...)
+}
+
+// narrate describes what changed since before, in the accessible mode
+func (m model) narrate(before model) model {
+	if !m.accessible {
+		return m
+	}
+
+	if m.gameState != before.gameState {
+		m = m.narrateState()
+	}
+	// New moves, from either side
+	if len(m.game.MoveHistory) < m.heard {
+		m.heard = 0
+	}
+	if moves := m.game.MoveHistory[m.heard:]; len(moves) > 0 {
+		var lines []string
+		for _, move := range moves {
+			lines = append(lines, describeMove(move)+".")
+		}
+		if m.isMyTurn {
+			lines[len(lines)-1] += " Your move."
+		}
+		m.heard = len(m.game.MoveHistory)
+		m = m.speak(lines...)
+	}
+
+	if m.notice != "" && m.notice != before.notice {
+		m = m.speak(m.notice)
+	}
+	if len(m.premoves) > len(before.premoves) {
+		p := m.premoves[len(m.premoves)-1]
+		m = m.speak(fmt.Sprintf("Premove %s to %s queued.", p.from, p.to))
+	}
+
+	if m.result != nil && before.result == nil {
+		m = m.speak(fmt.Sprintf("Game over: %s.", m.result))
+		if summary := strings.TrimSpace(m.ratingSummary()); summary != "" {
+			m = m.speak(strings.TrimSuffix(strings.ReplaceAll(summary, "\n", ". "), ".") + ".")
+		}
+		if m.gameState == "finished" {
+			m = m.speak("Type rematch to offer a rematch, or leave to go back to the lobby.")
+		}
+	}
+	if m.drawOffer == "received" && before.drawOffer != "received" {
+		m = m.speak("Your opponent offers a draw. Type accept or decline.")
+	}
+	if m.rematch == "received" && before.rematch != "received" {
+		m = m.speak("Your opponent wants a rematch. Type accept or decline.")
+	}
+	if len(m.challenges) > len(before.challenges) {
+		c := m.challenges[len(m.challenges)-1]
+		m = m.speak(fmt.Sprintf("%s challenges you: %s. You play %s. Type accept or decline.",
+			c.From.Name, c.Settings, challengedColor(c)))
+	}
+	if m.outgoing != nil && before.outgoing == nil {
+		m = m.speak(fmt.Sprintf("Challenged %s to %s. It expires in %d seconds; type cancel to withdraw it.",
+			m.outgoing.To.Name, m.outgoing.Settings, int(time.Until(m.outgoing.Expires).Seconds())))
+	}
+	if m.room != nil && before.room == nil {
+		m = m.speak(fmt.Sprintf("Private room %s is open for a %s game. Others join with: join %s. Type cancel to close it.",
+			m.room.Code, m.room.Settings, m.room.Code))
+	}
+	if len(m.chat) > len(before.chat) && !m.chatMuted {
+		for _, message := range m.chat[len(before.chat):] {
+			if message.From != m.player.Name {
+				m = m.speak(fmt.Sprintf("%s says: %s", message.From, message.Text))
+			}
+		}
+	}
+	return m
+}
+
+// narrateState announces arriving somewhere new: a game, the queue or the lobby
+func (m model) narrateState() model {
+	m.heard = len(m.game.MoveHistory)
+	var lines []string
+	switch m.gameState {
+	case "lobby":
+		lines = append(lines, "You're in the lobby. Type who to see who's online, or help for commands.")
+	case "waiting":
+		lines = append(lines, fmt.Sprintf("Looking for a %s %s game. Type cancel to stop looking.",
+			m.seekSettings.TimeControl, m.seekSettings.Variant.Title()))
+	case "playing":
+		if m.gameSession == nil || m.opponent == nil {
+			return m
+		}
+		lines = append(lines, fmt.Sprintf("Game started against %s, %s. You play %s.",
+			m.playerLabel(m.opponent), m.gameSession.GameSettings, m.player.Color))
+	case "spectating":
+		lines = append(lines, fmt.Sprintf("Watching %s as White against %s as Black, %s.",
+			m.playerLabel(m.gameSession.White), m.playerLabel(m.gameSession.Black), m.gameSession.GameSettings))
+	case "opponent_disconnected":
+		lines = append(lines, "Your opponent has left the game, so you win. Type leave to go back to the lobby.")
+	default:
+		return m
+	}
+
+	if m.gameState == "playing" || m.gameState == "spectating" {
+		if n := len(m.game.MoveHistory); n > 0 {
+			lines = append(lines, fmt.Sprintf("%d moves played; the last: %s.", n, describeMove(m.game.MoveHistory[n-1])))
+		}
+		if m.result == nil {
+			lines = append(lines, m.turnStatus())
+		}
+	}
+	return m.speak(lines...)
+}
+
+// turnStatus says whose move it is
+func (m model) turnStatus() string {
+	if m.result != nil {
+		return fmt.Sprintf("Game over: %s.", m.result)
+	}
+	status := fmt.Sprintf("%s to move.", m.game.CurrentTurn)
+	if m.isMyTurn {
+		status = fmt.Sprintf("Your move, as %s.", m.game.CurrentTurn)
+	}
+	if m.game.IsInCheck(m.game.CurrentTurn) {
+		status = fmt.Sprintf("%s is in check. %s", m.game.CurrentTurn, status)
+	}
+	return status
+}
+
+// describeClock reads out both clocks
+func (m model) describeClock() string {
+	if m.gameSession == nil {
+		return "You're not in a game."
+	}
+	white, ok := m.gameSession.ClockRemaining(White)
+	if !ok {
+		return "This game has no clock."
+	}
+	black, _ := m.gameSession.ClockRemaining(Black)
+	return fmt.Sprintf("White has %s, Black has %s.", formatClock(white), formatClock(black))
+}
+
+// describeMove reads a move out, e.g. "White played knight takes pawn on e5, check"
+func describeMove(move Move) string {
+	color, name := move.Piece.Color, pieceNames[move.Piece.Type]
+	var s string
+	switch {
+	case strings.HasPrefix(move.SAN, "O-O-O"):
+		s = fmt.Sprintf("%s castled queenside", color)
+	case strings.HasPrefix(move.SAN, "O-O"):
+		s = fmt.Sprintf("%s castled kingside", color)
+	default:
+		// Say where the piece came from when the notation had to
+		if move.Piece.Type != Pawn && len(normalizeMove(move.SAN)) > 3 {
+			name = fmt.Sprintf("%s from %s", name, move.From)
+		}
+		s = fmt.Sprintf("%s played %s %s", color, name, move.To)
+		if move.Captured.Type != Empty {
+			s = fmt.Sprintf("%s played %s takes %s on %s", color, name, pieceNames[move.Captured.Type], move.To)
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(move.SAN, "#"):
+		s += ", checkmate"
+	case strings.HasSuffix(move.SAN, "+"):
+		s += ", check"
+	}
+	return s
+}
+
+// describeMoveList reads out the moves so far in notation
+func describeMoveList(g *Game) string {
+	rows := g.moveRows()
+	if len(rows) == 0 {
+		return "No moves yet."
+	}
+	var parts []string
+	for i, row := range rows {
+		parts = append(parts, strings.TrimSpace(fmt.Sprintf("%d. %s %s", i+1, row[White], row[Black])))
+	}
+	return strings.Join(parts, ", ") + "."
+}
+
+// describeSquare reads out what stands on a square
+func describeSquare(g *Game, pos Position) string {
+	piece := g.Board.At(pos)
+	if piece.Type == Empty {
+		return fmt.Sprintf("%s empty", pos)
+	}
+	return fmt.Sprintf("%s %s %s", pos, strings.ToLower(piece.Color.String()), pieceNames[piece.Type])
+}
+
+// describeSquares reads out a rank or file, running empty squares together
+func describeSquares(g *Game, squares []Position) string {
+	var parts []string
+	for i := 0; i < len(squares); {
+		if g.Board.At(squares[i]).Type != Empty {
+			parts = append(parts, describeSquare(g, squares[i]))
+			i++
+			continue
+		}
+		j := i
+		for j+1 < len(squares) && g.Board.At(squares[j+1]).Type == Empty {
+			j++
+		}
+		switch j - i {
+		case 0:
+			parts = append(parts, fmt.Sprintf("%s empty", squares[i]))
+		case 1:
+			parts = append(parts, fmt.Sprintf("%s and %s empty", squares[i], squares[j]))
+		default:
+			parts = append(parts, fmt.Sprintf("%s to %s empty", squares[i], squares[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describePieces reads out where a side's pieces stand, e.g. "White: king e1,
+// rooks a1 and h1, ..."
+func describePieces(g *Game, color Color) string {
+	var parts []string
+	for _, kind := range pieceOrder {
+		squares := pieceSquares(g, Piece{kind, color})
+		if len(squares) == 0 {
+			continue
+		}
+		name := pieceNames[kind]
+		if len(squares) > 1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", name, listJoin(squares)))
+	}
+	return fmt.Sprintf("%s: %s.", color, strings.Join(parts, ", "))
+}
+
+// pieceSquares lists the squares a piece stands on, from a1 to h8
+func pieceSquares(g *Game, piece Piece) []string {
+	var squares []string
+	for row := range 8 {
+		for col := range 8 {
+			if g.Board[row][col] == piece {
+				squares = append(squares, Position{row, col}.String())
+			}
+		}
+	}
+	return squares
+}
+
+// describeLobby reads out the open seeks, live games and who's online
+func describeLobby(lobby Lobby, me *Player) []string {
+	var seeks, games, players []string
+	for _, seek := range lobby.Seeks {
+		if seek.Player.ID != me.ID {
+			seeks = append(seeks, fmt.Sprintf("%s %s for %s %s", seek.Player.Name, seek.Rating,
+				seek.Settings.TimeControl, seek.Settings.Variant.Title()))
+		}
+	}
+	for _, game := range lobby.Games {
+		games = append(games, fmt.Sprintf("%s against %s, %s, %d moves", game.White.Name, game.Black.Name, game.Settings, game.Moves))
+	}
+	for _, p := range lobby.Players {
+		if p.Player.ID != me.ID {
+			players = append(players, fmt.Sprintf("%s %s", p.Player.Name, p.Status))
+		}
+	}
+
+	lines := []string{"No open seeks; type seek to start one."}
+	if len(seeks) > 0 {
+		lines[0] = fmt.Sprintf("Open seeks: %s. Type play and a name to accept one.", strings.Join(seeks, "; "))
+	}
+	if len(games) > 0 {
+		lines = append(lines, fmt.Sprintf("Live games: %s. Type watch and a name to watch one.", strings.Join(games, "; ")))
+	} else {
+		lines = append(lines, "No live games.")
+	}
+	if len(players) > 0 {
+		lines = append(lines, fmt.Sprintf("Online: %s.", strings.Join(players, "; ")))
+	} else {
+		lines = append(lines, "Nobody else is online.")
+	}
+	return lines
+}
+
+// parseSquare parses a square like "e4"
+func parseSquare(s string) (Position, bool) {
+	s = strings.ToLower(s)
+	if len(s) != 2 {
+		return Position{}, false
+	}
+	pos := Position{int(s[1]) - '1', int(s[0]) - 'a'}
+	return pos, pos.Valid()
+}
+
+// listJoin joins items as in "a1, c1 and h1"
+func listJoin(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
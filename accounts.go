@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrAccountNotFound is returned when no account matches a lookup
+var ErrAccountNotFound = errors.New("account not found")
+
+var usernamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// Account is a registered identity bound to one or more SSH public keys
+type Account struct {
+	Username string            `json:"username"`
+	Keys     []string          `json:"keys"` // SHA256 fingerprints of the bound public keys
+	Created  time.Time         `json:"created"`
+	LastSeen time.Time         `json:"lastSeen"`
+	Ratings  map[string]Rating `json:"ratings,omitempty"` // keyed by rating pool, see ratingPool
+	Colors   ColorHistory      `json:"colors"`
+	Prefs    Preferences       `json:"preferences"`
+}
+
+// Preferences are per-player display settings that follow an account
+// between sessions
+type Preferences struct {
+	HideOpponentCursor bool   `json:"hideOpponentCursor,omitempty"` // Don't draw the other side's cursor and selection
+	Theme              string `json:"theme,omitempty"`              // Board theme, see themes
+	Pieces             string `json:"pieces,omitempty"`             // Piece set, see pieceSets
+	Bell               bool   `json:"bell,omitempty"`               // Ring the bell when it's our move, see alerts
+	Title              bool   `json:"title,omitempty"`              // Show whose move it is in the window title
+	Desktop            bool   `json:"desktop,omitempty"`            // Send a desktop notification when it's our move
+
+	Keys map[string][]string `json:"keys,omitempty"` // Keys by action, replacing the defaults in keyBindings
+}
+
+// HasKey reports whether the given fingerprint is bound to the account
+func (a *Account) HasKey(fingerprint string) bool {
+	return slices.Contains(a.Keys, fingerprint)
+}
+
+// AccountStore persists accounts so identities survive across sessions
+type AccountStore interface {
+	Get(username string) (*Account, error)
+	FindByKey(fingerprint string) (*Account, error)
+	Save(account *Account) error
+	// Update changes an account in place, so concurrent updates to
+	// different fields don't overwrite each other
+	Update(username string, fn func(*Account)) error
+	List() ([]*Account, error)
+}
+
+// FileAccountStore keeps all accounts in a single JSON file.
+// An empty path keeps accounts in memory only.
+type FileAccountStore struct {
+	path     string
+	accounts map[string]*Account
+	mu       sync.RWMutex
+}
+
+func NewFileAccountStore(path string) (*FileAccountStore, error) {
+	store := &FileAccountStore{
+		path:     path,
+		accounts: make(map[string]*Account),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts: %w", err)
+	}
+	return store, nil
+}
+
+func (s *FileAccountStore) Get(username string) (*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, exists := s.accounts[username]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	return copyAccount(account), nil
+}
+
+func (s *FileAccountStore) FindByKey(fingerprint string) (*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, account := range s.accounts {
+		if account.HasKey(fingerprint) {
+			return copyAccount(account), nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+func (s *FileAccountStore) Save(account *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts[account.Username] = copyAccount(account)
+	return s.flush()
+}
+
+func (s *FileAccountStore) Update(username string, fn func(*Account)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, exists := s.accounts[username]
+	if !exists {
+		return ErrAccountNotFound
+	}
+	account = copyAccount(account)
+	fn(account)
+	s.accounts[username] = account
+	return s.flush()
+}
+
+func (s *FileAccountStore) List() ([]*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, copyAccount(account))
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Username < accounts[j].Username
+	})
+	return accounts, nil
+}
+
+// flush writes all accounts to disk; callers must hold the write lock
+func (s *FileAccountStore) flush() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.accounts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode accounts: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func copyAccount(account *Account) *Account {
+	// Round-trip through JSON so nested maps and slices aren't shared
+	data, _ := json.Marshal(account)
+	var c Account
+	_ = json.Unmarshal(data, &c)
+	return &c
+}
+
+// writeFileAtomic replaces path with data without leaving partial files behind
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// publicKeyHandler accepts every key. It runs for keys the client only
+// offers, before proving it holds them, so it mustn't record anything; the
+// session finds the key that actually authenticated with s.PublicKey().
+func publicKeyHandler(ctx ssh.Context, key ssh.PublicKey) bool {
+	return true
+}
+
+// keyboardInteractiveHandler lets clients without a key in as guests
+func keyboardInteractiveHandler(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+	return true
+}
+
+// sessionFingerprint returns the fingerprint of the key the session
+// authenticated with, or "" for keyless sessions such as keyboard-interactive
+// ones, which are always guests
+func sessionFingerprint(s ssh.Session) string {
+	if key := s.PublicKey(); key != nil {
+		return gossh.FingerprintSHA256(key)
+	}
+	return ""
+}
+
+// guestName returns the clearly marked display name used for anonymous sessions
+func guestName(user string) string {
+	if user == "" {
+		user = "anonymous"
+	}
+	return fmt.Sprintf("%s (guest)", user)
+}
+
+// resolveIdentity maps a session to its account. Sessions whose key isn't
+// bound to an account get a guest name and an empty identity.
+func resolveIdentity(s ssh.Session) (name, identity, fingerprint string) {
+	fingerprint = sessionFingerprint(s)
+	if fingerprint == "" {
+		return guestName(s.User()), "", ""
+	}
+
+	accounts := GetGameManager().Accounts()
+	account, err := accounts.FindByKey(fingerprint)
+	if err != nil {
+		return guestName(s.User()), "", fingerprint
+	}
+
+	_ = accounts.Update(account.Username, func(a *Account) { a.LastSeen = time.Now() })
+	return account.Username, account.Username, fingerprint
+}
+
+// loadPreferences returns an identity's saved preferences, or the defaults
+// for guests
+func loadPreferences(accounts AccountStore, identity string) Preferences {
+	if identity == "" {
+		return Preferences{}
+	}
+	account, err := accounts.Get(identity)
+	if err != nil {
+		return Preferences{}
+	}
+	return account.Prefs
+}
+
+// savePreferences stores an identity's preferences. Guests' preferences
+// last until they disconnect, so there is nothing to save.
+func savePreferences(accounts AccountStore, identity string, prefs Preferences) error {
+	if identity == "" {
+		return nil
+	}
+	return accounts.Update(identity, func(a *Account) { a.Prefs = prefs })
+}
+
+// claimUsername binds username to the key with the given fingerprint
+func claimUsername(accounts AccountStore, username, fingerprint string) (*Account, error) {
+	if fingerprint == "" {
+		return nil, errors.New("claiming a username requires public key authentication")
+	}
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("invalid username %q: use 2-20 lowercase letters, digits, _ or -", username)
+	}
+
+	if existing, err := accounts.FindByKey(fingerprint); err == nil {
+		if existing.Username == username {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("this key is already bound to %q", existing.Username)
+	}
+
+	if _, err := accounts.Get(username); err == nil {
+		return nil, fmt.Errorf("username %q is already taken", username)
+	} else if !errors.Is(err, ErrAccountNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	account := &Account{
+		Username: username,
+		Keys:     []string{fingerprint},
+		Created:  now,
+		LastSeen: now,
+	}
+	if err := accounts.Save(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// addAccountKey binds an additional authorized_keys line to an account
+func addAccountKey(accounts AccountStore, username, authorizedKey string) (string, error) {
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+	fingerprint := gossh.FingerprintSHA256(key)
+
+	if existing, err := accounts.FindByKey(fingerprint); err == nil {
+		if existing.Username == username {
+			return fingerprint, nil
+		}
+		return "", fmt.Errorf("key is already bound to %q", existing.Username)
+	}
+
+	if err := accounts.Update(username, func(a *Account) { a.Keys = append(a.Keys, fingerprint) }); err != nil {
+		return "", err
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// impostorSigner offers someone else's public key without holding the
+// private key, so the client gives up on it after asking whether the server
+// would accept it, and moves on to the next auth method
+type impostorSigner struct {
+	offered gossh.PublicKey
+}
+
+func (s impostorSigner) PublicKey() gossh.PublicKey {
+	return s.offered
+}
+
+func (s impostorSigner) Sign(io.Reader, []byte) (*gossh.Signature, error) {
+	return nil, errors.New("no private key")
+}
+
+func newSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// identityServer starts a server with the real auth handlers that replies
+// with the identity each session resolves to
+func identityServer(t *testing.T) string {
+	t.Helper()
+	srv, err := wish.NewServer(
+		wish.WithPublicKeyAuth(publicKeyHandler),
+		wish.WithKeyboardInteractiveAuth(keyboardInteractiveHandler),
+		wish.WithMiddleware(func(next ssh.Handler) ssh.Handler {
+			return func(s ssh.Session) {
+				_, identity, _ := resolveIdentity(s)
+				_, _ = io.WriteString(s, identity)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return l.Addr().String()
+}
+
+func TestSessionIdentity(t *testing.T) {
+	accounts, _ := NewFileAccountStore("")
+	GetGameManager().SetAccountStore(accounts)
+
+	alice := newSigner(t)
+	if _, err := claimUsername(accounts, "alice", gossh.FingerprintSHA256(alice.PublicKey())); err != nil {
+		t.Fatal(err)
+	}
+	addr := identityServer(t)
+
+	keyboardInteractive := gossh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		return make([]string, len(questions)), nil
+	})
+
+	for _, tt := range []struct {
+		name string
+		auth []gossh.AuthMethod
+		want string
+	}{{
+		name: "alice's key",
+		auth: []gossh.AuthMethod{gossh.PublicKeys(alice)},
+		want: "alice",
+	}, {
+		name: "unbound key",
+		auth: []gossh.AuthMethod{gossh.PublicKeys(newSigner(t))},
+		want: "",
+	}, {
+		name: "keyboard-interactive",
+		auth: []gossh.AuthMethod{keyboardInteractive},
+		want: "",
+	}, {
+		name: "offering alice's key without holding it",
+		auth: []gossh.AuthMethod{
+			gossh.PublicKeys(impostorSigner{offered: alice.PublicKey()}),
+			keyboardInteractive,
+		},
+		want: "",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+				User:            "alice",
+				Auth:            tt.auth,
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			session, err := client.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+			out, err := session.Output("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(out); got != tt.want {
+				t.Errorf("identity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentAccountUpdates(t *testing.T) {
+	accounts, _ := NewFileAccountStore("")
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := claimUsername(accounts, name, "SHA256:"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Games finishing while alice changes her settings mustn't lose either
+	const games = 50
+	var wg sync.WaitGroup
+	for i := range games {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := applyRatings(accounts, "alice", "bob", "blitz", WhiteWins); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := savePreferences(accounts, "alice", Preferences{Bell: true, Theme: fmt.Sprint(i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	alice, err := accounts.Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := alice.Ratings["blitz"].Games; got != games {
+		t.Errorf("alice's rating counts %d games, want %d", got, games)
+	}
+	if !alice.Prefs.Bell {
+		t.Error("alice's preferences were lost")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// botName is the display name of the built-in computer opponent
+const botName = "CheSSH bot"
+
+var botCounter atomic.Int64
+
+var pieceValues = map[PieceType]int{
+	Pawn:   100,
+	Knight: 300,
+	Bishop: 320,
+	Rook:   500,
+	Queen:  900,
+}
+
+// LegalMoves returns every legal move for the side to move
+func (g *Game) LegalMoves() []Move {
+	var moves []Move
+	for row := range 8 {
+		for col := range 8 {
+			from := Position{row, col}
+			piece := g.Board.At(from)
+			if piece.Type == Empty || piece.Color != g.CurrentTurn {
+				continue
+			}
+			for toRow := range 8 {
+				for toCol := range 8 {
+					to := Position{toRow, toCol}
+					if move, ok := g.Clone().play(from, to); ok {
+						moves = append(moves, move)
+					}
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// PlayBot starts a casual game between the player and the built-in bot
+func (gm *GameManager) PlayBot(player *Player, settings GameSettings) {
+	bot := &Player{
+		ID:         fmt.Sprintf("bot_%d", botCounter.Add(1)),
+		Name:       botName,
+		Connected:  true,
+		UpdateChan: make(chan GameUpdate, 10),
+	}
+	settings.Rated = false
+	settings.Private = true
+
+	gm.mu.Lock()
+	white, black := gm.assignColorsLocked(player, bot, ColorRandom)
+	session := gm.startGameLocked(white, black, settings)
+	gm.mu.Unlock()
+
+	go runBot(bot, session)
+}
+
+// runBot plays the bot's side of a game until it's over
+func runBot(bot *Player, session *GameSession) {
+	defer GetGameManager().RemovePlayer(bot.ID)
+
+	play := func() {
+		if !session.IsPlayerTurn(bot.ID) {
+			return
+		}
+		session.mu.RLock()
+		position := session.Game.Clone()
+		session.mu.RUnlock()
+
+		// A short pause makes the bot feel less abrupt
+		time.Sleep(500 * time.Millisecond)
+		if move, ok := chooseBotMove(position); ok {
+			session.MakeMove(bot.ID, move.From, move.To)
+		}
+	}
+
+	play()
+	for {
+		select {
+		case <-session.ctx.Done():
+			return
+		case update := <-bot.UpdateChan:
+			switch update.Type {
+			case "move":
+				play()
+			case "game_over":
+				return
+			}
+		}
+	}
+}
+
+// chooseBotMove picks the move that leaves the best material balance after
+// the opponent's best reply, breaking ties randomly
+func chooseBotMove(g *Game) (Move, bool) {
+	moves := g.LegalMoves()
+	if len(moves) == 0 {
+		return Move{}, false
+	}
+	rand.Shuffle(len(moves), func(i, j int) { moves[i], moves[j] = moves[j], moves[i] })
+
+	me := g.CurrentTurn
+	best, bestScore := moves[0], -1<<31
+	for _, move := range moves {
+		after := g.Clone()
+		after.MakeMove(move.From, move.To)
+		if result := after.Result(); result != nil {
+			if winner, decisive := result.Winner(); decisive && winner == me {
+				return move, true
+			}
+		}
+
+		// Assume the opponent answers with their best capture
+		worst := materialBalance(after, me)
+		for _, reply := range after.LegalMoves() {
+			replied := after.Clone()
+			replied.MakeMove(reply.From, reply.To)
+			score := materialBalance(replied, me)
+			if result := replied.Result(); result != nil {
+				if _, decisive := result.Winner(); decisive {
+					score = -1 << 20
+				}
+			}
+			if score < worst {
+				worst = score
+			}
+		}
+		if worst > bestScore {
+			best, bestScore = move, worst
+		}
+	}
+	return best, true
+}
+
+// materialBalance is color's material minus the opponent's, in centipawns
+func materialBalance(g *Game, color Color) int {
+	balance := 0
+	for row := range 8 {
+		for col := range 8 {
+			piece := g.Board[row][col]
+			if piece.Color == color {
+				balance += pieceValues[piece.Type]
+			} else {
+				balance -= pieceValues[piece.Type]
+			}
+		}
+	}
+	return balance
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestBotTakesMate(t *testing.T) {
+	for _, fen := range []string{
+		"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1",
+		"r5k1/5ppp/8/8/8/8/5PPP/6K1 b - - 0 1",
+		"6rk/6pp/8/6N1/8/8/8/7K w - - 0 1",
+	} {
+		t.Run(fen, func(t *testing.T) {
+			// Ties are broken randomly, so try a few times
+			for range 10 {
+				g, err := NewGameFromFEN(fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				move, ok := chooseBotMove(g)
+				if !ok {
+					t.Fatal("the bot found no move")
+				}
+				if !g.MakeMove(move.From, move.To) || !g.IsCheckmate(g.CurrentTurn) {
+					t.Fatalf("the bot played %v%v instead of mating", move.From, move.To)
+				}
+			}
+		})
+	}
+}
+
+func TestBotKeepsQueen(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fen  string
+	}{
+		{"attacked by a pawn", "4k3/8/8/4p3/3Q4/8/8/4K3 w - - 0 1"},
+		{"defended pawn", "4k3/8/3p4/4p3/8/8/8/3QK3 w - - 0 1"},
+		{"attacked by a knight", "4k3/8/8/2n5/8/3Q4/8/4K3 w - - 0 1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 10 {
+				g, err := NewGameFromFEN(tt.fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				move, ok := chooseBotMove(g)
+				if !ok {
+					t.Fatal("the bot found no move")
+				}
+				g.MakeMove(move.From, move.To)
+				for _, reply := range g.LegalMoves() {
+					if g.Board.At(reply.To).Type == Queen {
+						t.Fatalf("the bot played %v%v, and %v%v takes its queen", move.From, move.To, reply.From, reply.To)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// challengeTimeout is how long a challenge waits for an answer
+const challengeTimeout = time.Minute
+
+// Color preferences for challenges
+const (
+	ColorRandom = "random"
+	ColorWhite  = "white"
+	ColorBlack  = "black"
+)
+
+// Challenge is an invitation from one player to another to play a game
+type Challenge struct {
+	ID       string
+	From     *Player
+	To       *Player
+	Settings GameSettings
+	Color    string // The challenger's color preference
+	Expires  time.Time
+}
+
+// ParseChallengeArgs parses a challenge like "alice 5+3 black threecheck casual".
+// The username comes first; the other options may be given in any order.
+func ParseChallengeArgs(args []string) (string, GameSettings, string, error) {
+	settings := GameSettings{TimeControl: DefaultTimeControl, Rated: true}
+	color := ColorRandom
+
+	if len(args) == 0 {
+		return "", settings, color, errors.New("usage: challenge <user> [minutes+increment] [white|black|random] [variant] [rated|casual]")
+	}
+	target := args[0]
+
+	for _, arg := range args[1:] {
+		arg = strings.ToLower(arg)
+		switch arg {
+		case ColorWhite, ColorBlack, ColorRandom:
+			color = arg
+		case "rated":
+			settings.Rated = true
+		case "casual":
+			settings.Rated = false
+		default:
+			if isTimeControl(arg) {
+				tc, err := ParseTimeControl(arg)
+				if err != nil {
+					return "", settings, color, err
+				}
+				settings.TimeControl = tc
+				continue
+			}
+			variant, err := ParseVariant(arg)
+			if err != nil {
+				return "", settings, color, fmt.Errorf("unknown challenge option %q", arg)
+			}
+			settings.Variant = variant
+		}
+	}
+	return target, settings, color, nil
+}
+
+// CreateChallenge sends a challenge from one player to an online player
+func (gm *GameManager) CreateChallenge(from *Player, target string, settings GameSettings, color string) (*Challenge, error) {
+	to := gm.FindOnlinePlayer(target)
+	if to == nil {
+		return nil, fmt.Errorf("%s is not online", target)
+	}
+	if to.ID == from.ID {
+		return nil, errors.New("you can't challenge yourself")
+	}
+	if err := checkCorrespondence(settings, from, to); err != nil {
+		return nil, err
+	}
+	if settings.Rated && !canBeRated(from, to) {
+		return nil, errors.New("rated challenges need both players to have claimed a username; add \"casual\" to play anyway")
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.inGameLocked(to.ID) {
+		return nil, fmt.Errorf("%s is in the middle of a game", to.Name)
+	}
+
+	// A new challenge replaces any challenge the player already sent
+	for id, existing := range gm.challenges {
+		if existing.From.ID == from.ID {
+			delete(gm.challenges, id)
+			notifyPlayer(existing.To, GameUpdate{Type: "challenge_cancelled", Data: existing})
+		}
+	}
+
+	challenge := &Challenge{
+		ID:       fmt.Sprintf("challenge_%d", time.Now().UnixNano()),
+		From:     from,
+		To:       to,
+		Settings: settings,
+		Color:    color,
+		Expires:  time.Now().Add(challengeTimeout),
+	}
+	gm.challenges[challenge.ID] = challenge
+
+	notifyPlayer(to, GameUpdate{Type: "challenge", Data: challenge})
+	return challenge, nil
+}
+
+// AcceptChallenge starts the challenged game, if the challenge is still open
+func (gm *GameManager) AcceptChallenge(challengeID, playerID string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	challenge, exists := gm.challenges[challengeID]
+	if !exists || challenge.To.ID != playerID {
+		return errors.New("the challenge is no longer available")
+	}
+	if _, online := gm.players[challenge.From.ID]; !online || gm.inGameLocked(challenge.From.ID) {
+		delete(gm.challenges, challengeID)
+		return fmt.Errorf("%s is no longer available", challenge.From.Name)
+	}
+	delete(gm.challenges, challengeID)
+
+	white, black := gm.assignColorsLocked(challenge.From, challenge.To, challenge.Color)
+	gm.startGameLocked(white, black, challenge.Settings)
+	return nil
+}
+
+// DeclineChallenge refuses a challenge and tells the challenger
+func (gm *GameManager) DeclineChallenge(challengeID, playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	challenge, exists := gm.challenges[challengeID]
+	if !exists || challenge.To.ID != playerID {
+		return
+	}
+	delete(gm.challenges, challengeID)
+	notifyPlayer(challenge.From, GameUpdate{Type: "challenge_declined", Data: challenge})
+}
+
+// CancelChallenge withdraws a challenge the player sent
+func (gm *GameManager) CancelChallenge(challengeID, playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	challenge, exists := gm.challenges[challengeID]
+	if !exists || challenge.From.ID != playerID {
+		return
+	}
+	delete(gm.challenges, challengeID)
+	notifyPlayer(challenge.To, GameUpdate{Type: "challenge_cancelled", Data: challenge})
+}
+
+// cancelChallengesLocked withdraws every challenge to or from the player,
+// e.g. because they left or started another game. Callers must hold gm.mu.
+func (gm *GameManager) cancelChallengesLocked(playerID string) {
+	for id, challenge := range gm.challenges {
+		switch playerID {
+		case challenge.From.ID:
+			notifyPlayer(challenge.To, GameUpdate{Type: "challenge_cancelled", Data: challenge})
+		case challenge.To.ID:
+			notifyPlayer(challenge.From, GameUpdate{Type: "challenge_cancelled", Data: challenge})
+		default:
+			continue
+		}
+		delete(gm.challenges, id)
+	}
+}
+
+// expireChallengesLocked drops challenges nobody answered in time.
+// Callers must hold gm.mu.
+func (gm *GameManager) expireChallengesLocked(now time.Time) {
+	for id, challenge := range gm.challenges {
+		if now.After(challenge.Expires) {
+			delete(gm.challenges, id)
+			update := GameUpdate{Type: "challenge_expired", Data: challenge}
+			notifyPlayer(challenge.From, update)
+			notifyPlayer(challenge.To, update)
+		}
+	}
+}
+
+// inGameLocked reports whether the player is in a game that hasn't finished.
+// Callers must hold gm.mu.
+func (gm *GameManager) inGameLocked(playerID string) bool {
+	gameID, exists := gm.playerToGame[playerID]
+	if !exists {
+		return false
+	}
+	session, exists := gm.activeGames[gameID]
+	if !exists {
+		return false
+	}
+	result, _ := session.GetResult()
+	return result == nil
+}
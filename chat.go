@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	chatHistoryLimit = 100 // Messages kept per game
+	chatMaxLength    = 200 // Longer messages are cut off
+	chatRateLimit    = 5   // Messages allowed per chatRateWindow
+	chatRateWindow   = 10 * time.Second
+	chatPaneLines    = 8 // Messages shown next to the board
+)
+
+// ChatMessage is something said during a game. Spectator messages are only
+// shown to other spectators, so onlookers can't give the players hints.
+type ChatMessage struct {
+	From      string
+	Spectator bool
+	Text      string
+	Time      time.Time
+}
+
+func (c ChatMessage) String() string {
+	from := c.From
+	if c.Spectator {
+		from += " (watching)"
+	}
+	return fmt.Sprintf("[%s] %s: %s", c.Time.Format("15:04"), from, c.Text)
+}
+
+// sanitizeChat strips control characters, so messages can't move the cursor
+// or change colors in other players' terminals, and limits their length
+func sanitizeChat(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+	if r := []rune(text); len(r) > chatMaxLength {
+		text = string(r[:chatMaxLength])
+	}
+	return text
+}
+
+// Say sends a chat message from a player or spectator of the game
+func (gs *GameSession) Say(sender *Player, text string) error {
+	text = sanitizeChat(text)
+	if text == "" {
+		return nil
+	}
+
+	spectator := gs.GetPlayer(sender.ID) == nil
+
+	gs.mu.Lock()
+	now := time.Now()
+
+	// Only count messages inside the window against the limit
+	recent := gs.chatTimes[sender.ID][:0]
+	for _, t := range gs.chatTimes[sender.ID] {
+		if now.Sub(t) < chatRateWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= chatRateLimit {
+		gs.chatTimes[sender.ID] = recent
+		gs.mu.Unlock()
+		return errors.New("you're sending messages too quickly")
+	}
+	gs.chatTimes[sender.ID] = append(recent, now)
+
+	message := ChatMessage{From: sender.Name, Spectator: spectator, Text: text, Time: now}
+	gs.Chat = append(gs.Chat, message)
+	if len(gs.Chat) > chatHistoryLimit {
+		gs.Chat = gs.Chat[len(gs.Chat)-chatHistoryLimit:]
+	}
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "chat", Data: message, SpectatorsOnly: spectator})
+	return nil
+}
+
+// ChatHistory returns the game's chat so far. Players don't see what
+// spectators said.
+func (gs *GameSession) ChatHistory(spectator bool) []ChatMessage {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	var history []ChatMessage
+	for _, message := range gs.Chat {
+		if !message.Spectator || spectator {
+			history = append(history, message)
+		}
+	}
+	return history
+}
+
+// getChatLines renders the most recent chat messages for the pane next to the board
+func (m model) getChatLines() []string {
+	if m.gameSession == nil {
+		return nil
+	}
+
+	lines := []string{"", "CHAT (T to talk, M to mute)"}
+	if m.chatMuted {
+		return append(lines, "  muted")
+	}
+	messages := m.chat
+	if len(messages) > chatPaneLines {
+		messages = messages[len(messages)-chatPaneLines:]
+	}
+	if len(messages) == 0 {
+		lines = append(lines, "  no messages yet")
+	}
+	for _, message := range messages {
+		lines = append(lines, "  "+message.String())
+	}
+	return lines
+}
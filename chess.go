@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
 
 type Color int
 
@@ -80,6 +84,8 @@ func (p Position) String() string {
 type Move struct {
 	From, To Position
 	Piece    Piece
+	Captured Piece  // Empty unless the move took a piece
+	SAN      string // Standard algebraic notation, e.g. "Nxe5+"
 }
 
 type Board [8][8]Piece
@@ -142,6 +148,66 @@ func (b *Board) Move(from, to Position) bool {
 	return true
 }
 
+// Variant selects the rules used to decide who wins
+type Variant int
+
+const (
+	Standard Variant = iota
+	KingOfTheHill
+	ThreeCheck
+)
+
+var variantNames = map[Variant]string{
+	Standard:      "standard",
+	KingOfTheHill: "kingofthehill",
+	ThreeCheck:    "threecheck",
+}
+
+func (v Variant) String() string {
+	return variantNames[v]
+}
+
+// Title returns the human-readable variant name
+func (v Variant) Title() string {
+	switch v {
+	case KingOfTheHill:
+		return "King of the Hill"
+	case ThreeCheck:
+		return "Three-check"
+	}
+	return "Standard"
+}
+
+func ParseVariant(s string) (Variant, error) {
+	s = strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(s))
+	for v, name := range variantNames {
+		if s == name {
+			return v, nil
+		}
+	}
+	switch s {
+	case "koth", "hill":
+		return KingOfTheHill, nil
+	case "3check":
+		return ThreeCheck, nil
+	}
+	return Standard, fmt.Errorf("unknown variant %q", s)
+}
+
+// MarshalText stores variants by name, so stored games don't depend on the constants' order
+func (v Variant) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Variant) UnmarshalText(text []byte) error {
+	variant, err := ParseVariant(string(text))
+	if err != nil {
+		return err
+	}
+	*v = variant
+	return nil
+}
+
 type Game struct {
 	Board           Board
 	CurrentTurn     Color
@@ -149,6 +215,8 @@ type Game struct {
 	KingMoved       [2]bool
 	RookMoved       [2][2]bool
 	EnPassantTarget *Position
+	Variant         Variant
+	Checks          [2]int // Checks given by each color, for Three-check
 }
 
 func NewGame() *Game {
@@ -162,6 +230,112 @@ func NewGame() *Game {
 	}
 }
 
+func NewVariantGame(variant Variant) *Game {
+	g := NewGame()
+	g.Variant = variant
+	return g
+}
+
+// NewGameFromFEN sets up a game from the first four fields of a FEN string:
+// piece placement, side to move, castling rights and en passant square
+func NewGameFromFEN(fen string) (*Game, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid FEN %q: want at least 4 fields", fen)
+	}
+
+	g := NewGame()
+	for row := range 8 {
+		for col := range 8 {
+			g.Board[row][col] = Piece{Empty, White}
+		}
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: want 8 ranks", fen)
+	}
+	pieceTypes := map[rune]PieceType{'p': Pawn, 'r': Rook, 'n': Knight, 'b': Bishop, 'q': Queen, 'k': King}
+	for i, rank := range ranks {
+		row, col := 7-i, 0
+		for _, c := range rank {
+			if c >= '1' && c <= '8' {
+				col += int(c - '0')
+				continue
+			}
+			pieceType, ok := pieceTypes[unicode.ToLower(c)]
+			if !ok || col > 7 {
+				return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, rank)
+			}
+			color := White
+			if unicode.IsLower(c) {
+				color = Black
+			}
+			g.Board[row][col] = Piece{pieceType, color}
+			col++
+		}
+		if col != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: rank %q doesn't have 8 squares", fen, rank)
+		}
+	}
+	for _, color := range []Color{White, Black} {
+		if !g.FindKing(color).Valid() {
+			return nil, fmt.Errorf("invalid FEN %q: %s has no king", fen, color)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		g.CurrentTurn = White
+	case "b":
+		g.CurrentTurn = Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: side to move must be w or b", fen)
+	}
+	if g.IsInCheck(1 - g.CurrentTurn) {
+		return nil, fmt.Errorf("invalid FEN %q: the side not to move is in check", fen)
+	}
+
+	// Castling rights are tracked as "has the king or rook moved"
+	g.KingMoved = [2]bool{true, true}
+	g.RookMoved = [2][2]bool{{true, true}, {true, true}}
+	castling := map[rune]struct {
+		color Color
+		side  int
+	}{'K': {White, 1}, 'Q': {White, 0}, 'k': {Black, 1}, 'q': {Black, 0}}
+	if fields[2] != "-" {
+		for _, c := range fields[2] {
+			right, ok := castling[c]
+			if !ok {
+				return nil, fmt.Errorf("invalid FEN %q: bad castling rights %q", fen, fields[2])
+			}
+			row := 0
+			if right.color == Black {
+				row = 7
+			}
+			if g.Board.At(Position{row, 4}) != (Piece{King, right.color}) ||
+				g.Board.At(Position{row, right.side * 7}) != (Piece{Rook, right.color}) {
+				return nil, fmt.Errorf("invalid FEN %q: castling right %c without king and rook in place", fen, c)
+			}
+			g.KingMoved[right.color] = false
+			g.RookMoved[right.color][right.side] = false
+		}
+	}
+
+	if fields[3] != "-" {
+		if len(fields[3]) != 2 {
+			return nil, fmt.Errorf("invalid FEN %q: bad en passant square %q", fen, fields[3])
+		}
+		target := Position{int(fields[3][1] - '1'), int(fields[3][0] - 'a')}
+		if !target.Valid() {
+			return nil, fmt.Errorf("invalid FEN %q: bad en passant square %q", fen, fields[3])
+		}
+		g.EnPassantTarget = &target
+	}
+
+	return g, nil
+}
+
 func (g *Game) IsValidMove(from, to Position) bool {
 	if !from.Valid() || !to.Valid() {
 		return false
@@ -327,26 +501,52 @@ func (g *Game) isPathClear(from, to Position) bool {
 }
 
 func (g *Game) MakeMove(from, to Position) bool {
-	if !g.IsValidMove(from, to) {
+	san := g.san(from, to)
+	move, ok := g.play(from, to)
+	if !ok {
 		return false
 	}
 
+	switch {
+	case g.IsCheckmate(g.CurrentTurn):
+		san += "#"
+	case g.IsInCheck(g.CurrentTurn):
+		san += "+"
+	}
+	move.SAN = san
+	g.MoveHistory[len(g.MoveHistory)-1] = move
+	return true
+}
+
+// play makes a move without working out its notation, for trying moves out
+func (g *Game) play(from, to Position) (Move, bool) {
+	if !g.IsValidMove(from, to) {
+		return Move{}, false
+	}
+
 	piece := g.Board.At(from)
-	move := Move{from, to, piece}
 	originalTarget := g.Board.At(to)
+	move := Move{From: from, To: to, Piece: piece, Captured: originalTarget}
+	if piece.Type == Pawn && g.EnPassantTarget != nil && *g.EnPassantTarget == to {
+		move.Captured = Piece{Pawn, 1 - piece.Color}
+	}
 
 	g.executeMove(from, to, piece)
 
 	if g.IsInCheck(g.CurrentTurn) {
 		g.undoMove(from, to, piece, originalTarget)
-		return false
+		return Move{}, false
 	}
 
 	g.updateGameState(from, to, piece)
 	g.MoveHistory = append(g.MoveHistory, move)
 	g.CurrentTurn = 1 - g.CurrentTurn
 
-	return true
+	if g.IsInCheck(g.CurrentTurn) {
+		g.Checks[piece.Color]++
+	}
+
+	return move, true
 }
 
 func (g *Game) executeMove(from, to Position, piece Piece) {
@@ -546,6 +746,164 @@ func (g *Game) isValidMoveIgnoringCheck(from, to Position) bool {
 	return g.isValidPieceMove(piece, from, to)
 }
 
+// IsStalemate reports whether color is not in check but has no legal move
+func (g *Game) IsStalemate(color Color) bool {
+	return !g.IsInCheck(color) && !g.HasLegalMove(color)
+}
+
+// HasLegalMove reports whether color has any move that doesn't leave its king in check
+func (g *Game) HasLegalMove(color Color) bool {
+	for row := range 8 {
+		for col := range 8 {
+			from := Position{row, col}
+			piece := g.Board.At(from)
+			if piece.Type == Empty || piece.Color != color {
+				continue
+			}
+			for toRow := range 8 {
+				for toCol := range 8 {
+					to := Position{toRow, toCol}
+					if !g.isValidMoveIgnoringCheck(from, to) {
+						continue
+					}
+					trial := g.Clone()
+					trial.CurrentTurn = color
+					if _, ok := trial.play(from, to); ok {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the game that can be modified independently
+func (g *Game) Clone() *Game {
+	c := *g
+	c.MoveHistory = append([]Move(nil), g.MoveHistory...)
+	if g.EnPassantTarget != nil {
+		target := *g.EnPassantTarget
+		c.EnPassantTarget = &target
+	}
+	return &c
+}
+
+// Outcome is the final result of a game
+type Outcome int
+
+const (
+	Ongoing Outcome = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+// Win returns the outcome in which color wins
+func Win(color Color) Outcome {
+	if color == White {
+		return WhiteWins
+	}
+	return BlackWins
+}
+
+func (o Outcome) String() string {
+	switch o {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// MarshalText stores outcomes as scores like "1-0"
+func (o Outcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+func (o *Outcome) UnmarshalText(text []byte) error {
+	for _, outcome := range []Outcome{Ongoing, WhiteWins, BlackWins, Draw} {
+		if string(text) == outcome.String() {
+			*o = outcome
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown outcome %q", text)
+}
+
+// Reasons a game can end
+const (
+	ReasonCheckmate   = "checkmate"
+	ReasonStalemate   = "stalemate"
+	ReasonTimeout     = "timeout"
+	ReasonAbandonment = "abandonment"
+	ReasonKingOfHill  = "king of the hill"
+	ReasonThreeChecks = "three checks"
+	ReasonResignation = "resignation"
+	ReasonAgreement   = "agreement"
+	ReasonForfeit     = "forfeit"
+)
+
+// GameResult describes how a game ended
+type GameResult struct {
+	Outcome Outcome
+	Reason  string
+}
+
+// Winner returns the winning color, or false for draws
+func (r GameResult) Winner() (Color, bool) {
+	switch r.Outcome {
+	case WhiteWins:
+		return White, true
+	case BlackWins:
+		return Black, true
+	}
+	return White, false
+}
+
+func (r GameResult) String() string {
+	winner, decisive := r.Winner()
+	if !decisive {
+		return fmt.Sprintf("Draw by %s", r.Reason)
+	}
+	switch r.Reason {
+	case ReasonTimeout:
+		return fmt.Sprintf("%s ran out of time; %s wins", 1-winner, winner)
+	case ReasonAbandonment:
+		return fmt.Sprintf("%s left the game; %s wins", 1-winner, winner)
+	case ReasonResignation:
+		return fmt.Sprintf("%s resigned; %s wins", 1-winner, winner)
+	}
+	return fmt.Sprintf("%s wins by %s", winner, r.Reason)
+}
+
+// Result returns the result if the position is terminal, or nil if play continues
+func (g *Game) Result() *GameResult {
+	if g.IsCheckmate(g.CurrentTurn) {
+		return &GameResult{Outcome: Win(1 - g.CurrentTurn), Reason: ReasonCheckmate}
+	}
+	if g.IsStalemate(g.CurrentTurn) {
+		return &GameResult{Outcome: Draw, Reason: ReasonStalemate}
+	}
+
+	mover := 1 - g.CurrentTurn
+	switch g.Variant {
+	case KingOfTheHill:
+		king := g.FindKing(mover)
+		if (king.Row == 3 || king.Row == 4) && (king.Col == 3 || king.Col == 4) {
+			return &GameResult{Outcome: Win(mover), Reason: ReasonKingOfHill}
+		}
+	case ThreeCheck:
+		if g.Checks[mover] >= 3 {
+			return &GameResult{Outcome: Win(mover), Reason: ReasonThreeChecks}
+		}
+	}
+	return nil
+}
+
 func (g *Game) GameStatus() string {
 	if g.IsCheckmate(g.CurrentTurn) {
 		winner := "White"
@@ -555,6 +913,10 @@ func (g *Game) GameStatus() string {
 		return fmt.Sprintf("Checkmate! %s wins!", winner)
 	}
 
+	if g.IsStalemate(g.CurrentTurn) {
+		return "Stalemate! The game is a draw."
+	}
+
 	if g.IsInCheck(g.CurrentTurn) {
 		return fmt.Sprintf("%s is in check!", g.CurrentTurn)
 	}
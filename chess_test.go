@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewGameFromFEN(t *testing.T) {
+	g, err := NewGameFromFEN(startFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Board != NewBoard() {
+		t.Error("the starting position's board differs from NewBoard")
+	}
+	if g.CurrentTurn != White || g.KingMoved != [2]bool{} || g.RookMoved != [2][2]bool{} || g.EnPassantTarget != nil {
+		t.Errorf("the starting position has turn %s, kings moved %v, rooks moved %v, en passant %v; want a fresh game",
+			g.CurrentTurn, g.KingMoved, g.RookMoved, g.EnPassantTarget)
+	}
+
+	g, err = NewGameFromFEN("r3k2r/8/8/3pP3/8/8/8/4K2R w Kq d6 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for square, want := range map[string]Piece{"a8": {Rook, Black}, "e8": {King, Black}, "d5": {Pawn, Black}, "e5": {Pawn, White}, "h1": {Rook, White}, "a1": {Empty, White}} {
+		pos, _ := parseSquare(square)
+		if got := g.Board.At(pos); got != want {
+			t.Errorf("%s = %v, want %v", square, got, want)
+		}
+	}
+	if g.KingMoved != [2]bool{false, false} || g.RookMoved != [2][2]bool{{true, false}, {false, true}} {
+		t.Errorf("kings moved %v, rooks moved %v; want White to castle only kingside and Black only queenside", g.KingMoved, g.RookMoved)
+	}
+	if g.EnPassantTarget == nil || g.EnPassantTarget.String() != "d6" {
+		t.Errorf("en passant square = %v, want d6", g.EnPassantTarget)
+	}
+	if !g.IsValidMove(Position{4, 4}, Position{5, 3}) {
+		t.Error("exd6 en passant isn't legal")
+	}
+
+	g, err = NewGameFromFEN("4k3/8/8/8/8/8/8/4K3 b - -")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.CurrentTurn != Black || g.KingMoved != [2]bool{true, true} {
+		t.Errorf("turn %s, kings moved %v; want Black to move without castling", g.CurrentTurn, g.KingMoved)
+	}
+}
+
+func TestNewGameFromFENErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"too few fields", "4k3/8/8/8/8/8/8/4K3 w -", "want at least 4 fields"},
+		{"too few ranks", "4k3/8/8/8/8/8/4K3 w - -", "want 8 ranks"},
+		{"bad piece", "4k3/8/8/8/8/8/8/4K2X w - - 0 1", "bad rank"},
+		{"long rank", "4k3/8/8/8/8/8/8/4K4 w - - 0 1", "doesn't have 8 squares"},
+		{"short rank", "4k3/8/8/8/8/8/8/4K2 w - - 0 1", "doesn't have 8 squares"},
+		{"too many pieces in a rank", "4k3/8/8/8/8/8/8/4K2RR w - - 0 1", "bad rank"},
+		{"missing king", "8/8/8/8/8/8/8/4K3 w - - 0 1", "Black has no king"},
+		{"bad side to move", "4k3/8/8/8/8/8/8/4K3 x - - 0 1", "side to move"},
+		{"side not to move in check", "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "not to move is in check"},
+		{"bad castling letter", "4k3/8/8/8/8/8/8/4K2R w X - 0 1", "bad castling rights"},
+		{"castling without the rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", "without king and rook"},
+		{"bad en passant square", "4k3/8/8/8/8/8/8/4K3 w - z9 0 1", "bad en passant square"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGameFromFEN(tt.fen)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl is the base time per side plus the increment added after each move,
+// or for correspondence games the number of days each side has per move.
+// The zero value is an untimed game.
+type TimeControl struct {
+	Initial   time.Duration `json:"initial"`
+	Increment time.Duration `json:"increment"`
+	Days      int           `json:"days,omitempty"`
+}
+
+// maxCorrespondenceDays is the longest anyone may take over a move
+const maxCorrespondenceDays = 14
+
+// DefaultTimeControl is used for quick pairing when no time control is chosen
+var DefaultTimeControl = TimeControl{Initial: 10 * time.Minute}
+
+func (tc TimeControl) Untimed() bool {
+	return tc.Initial == 0 && tc.Increment == 0 && tc.Days == 0
+}
+
+// Correspondence reports whether players get days per move, and may log off between moves
+func (tc TimeControl) Correspondence() bool {
+	return tc.Days > 0
+}
+
+// PerMove is how long each move may take in a correspondence game
+func (tc TimeControl) PerMove() time.Duration {
+	return time.Duration(tc.Days) * 24 * time.Hour
+}
+
+// Category buckets the time control by estimated game duration, the same way
+// most servers do: initial time plus 40 increments
+func (tc TimeControl) Category() string {
+	if tc.Untimed() {
+		return "unlimited"
+	}
+	if tc.Correspondence() {
+		return "correspondence"
+	}
+	estimate := tc.Initial + 40*tc.Increment
+	switch {
+	case estimate < 3*time.Minute:
+		return "bullet"
+	case estimate < 8*time.Minute:
+		return "blitz"
+	case estimate < 25*time.Minute:
+		return "rapid"
+	default:
+		return "classical"
+	}
+}
+
+func (tc TimeControl) String() string {
+	if tc.Untimed() {
+		return "unlimited"
+	}
+	if tc.Correspondence() {
+		return fmt.Sprintf("%dd", tc.Days)
+	}
+	minutes := tc.Initial.Minutes()
+	if minutes == float64(int(minutes)) {
+		return fmt.Sprintf("%d+%d", int(minutes), int(tc.Increment.Seconds()))
+	}
+	return fmt.Sprintf("%g+%d", minutes, int(tc.Increment.Seconds()))
+}
+
+// isTimeControl reports whether a command argument looks like a time control
+// rather than another option
+func isTimeControl(arg string) bool {
+	if strings.Contains(arg, "+") || arg == "unlimited" {
+		return true
+	}
+	// Correspondence games, like "3d"; variant names such as "standard" end in d too
+	return len(arg) > 1 && strings.HasSuffix(arg, "d") && arg[0] >= '0' && arg[0] <= '9'
+}
+
+// ParseTimeControl parses "minutes+increment" such as "5+3" or "0.5+0",
+// days per move for a correspondence game such as "3d", or "unlimited" for
+// an untimed game
+func ParseTimeControl(s string) (TimeControl, error) {
+	if s == "unlimited" || s == "-" {
+		return TimeControl{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		d, err := strconv.Atoi(days)
+		if err != nil || d < 1 || d > maxCorrespondenceDays {
+			return TimeControl{}, fmt.Errorf("invalid correspondence time control %q, want 1d to %dd", s, maxCorrespondenceDays)
+		}
+		return TimeControl{Days: d}, nil
+	}
+	minutes, increment, ok := strings.Cut(s, "+")
+	if !ok {
+		return TimeControl{}, fmt.Errorf("invalid time control %q, want minutes+increment like 5+3", s)
+	}
+	m, err := strconv.ParseFloat(minutes, 64)
+	if err != nil || m < 0 || m > 180 {
+		return TimeControl{}, fmt.Errorf("invalid minutes in time control %q", s)
+	}
+	inc, err := strconv.Atoi(increment)
+	if err != nil || inc < 0 || inc > 180 {
+		return TimeControl{}, fmt.Errorf("invalid increment in time control %q", s)
+	}
+	tc := TimeControl{
+		Initial:   time.Duration(m * float64(time.Minute)),
+		Increment: time.Duration(inc) * time.Second,
+	}
+	if tc.Initial == 0 && tc.Increment == 0 {
+		return TimeControl{}, fmt.Errorf("time control %q has no time", s)
+	}
+	return tc, nil
+}
+
+// Clock tracks remaining time for both sides. It doesn't run until White's
+// first move so nobody loses time while their opponent is still loading.
+// In correspondence games each move gets a fresh allowance instead.
+type Clock struct {
+	Remaining [2]time.Duration
+	Increment time.Duration
+	perMove   time.Duration // Set for correspondence games
+	running   bool
+	turn      Color
+	lastPress time.Time
+}
+
+func NewClock(tc TimeControl) *Clock {
+	if tc.Correspondence() {
+		return &Clock{
+			Remaining: [2]time.Duration{tc.PerMove(), tc.PerMove()},
+			perMove:   tc.PerMove(),
+		}
+	}
+	return &Clock{
+		Remaining: [2]time.Duration{tc.Initial, tc.Initial},
+		Increment: tc.Increment,
+	}
+}
+
+// Start runs turn's clock from the given instant, e.g. from the start of a
+// correspondence game or its last move
+func (c *Clock) Start(turn Color, at time.Time) {
+	c.running = true
+	c.turn = turn
+	c.lastPress = at
+}
+
+// Press stops the mover's clock, adds the increment and starts the opponent's
+func (c *Clock) Press(mover Color, now time.Time) {
+	if c.perMove > 0 {
+		c.Remaining[mover] = c.perMove
+	} else if c.running {
+		c.Remaining[mover] -= now.Sub(c.lastPress)
+		c.Remaining[mover] += c.Increment
+	}
+	c.running = true
+	c.turn = 1 - mover
+	c.lastPress = now
+}
+
+// Stop freezes both clocks, e.g. when the game ends
+func (c *Clock) Stop(now time.Time) {
+	if c.running {
+		c.Remaining[c.turn] -= now.Sub(c.lastPress)
+		c.running = false
+	}
+}
+
+// RemainingAt returns how much time color has left at the given instant
+func (c *Clock) RemainingAt(color Color, now time.Time) time.Duration {
+	remaining := c.Remaining[color]
+	if c.running && c.turn == color {
+		remaining -= now.Sub(c.lastPress)
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Flagged returns the color that has run out of time, if any
+func (c *Clock) Flagged(now time.Time) (Color, bool) {
+	if c.running && c.RemainingAt(c.turn, now) == 0 {
+		return c.turn, true
+	}
+	return White, false
+}
+
+func formatClock(d time.Duration) string {
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+	if d >= time.Hour {
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	if d < 10*time.Second {
+		return fmt.Sprintf("%d.%d", int(d.Seconds()), int(d.Milliseconds()/100)%10)
+	}
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+)
+
+// recentColorGames is how many recent games count towards a player's color balance
+const recentColorGames = 10
+
+// ColorHistory counts the colors a player has had, so they get White and
+// Black about equally often
+type ColorHistory struct {
+	White  int    `json:"white"`
+	Black  int    `json:"black"`
+	Recent string `json:"recent,omitempty"` // "w" or "b" per game, most recent last
+}
+
+// record adds a game played as color
+func (h ColorHistory) record(color Color) ColorHistory {
+	mark := "b"
+	if color == White {
+		h.White++
+		mark = "w"
+	} else {
+		h.Black++
+	}
+	h.Recent += mark
+	if len(h.Recent) > recentColorGames {
+		h.Recent = h.Recent[len(h.Recent)-recentColorGames:]
+	}
+	return h
+}
+
+// RecentBalance is how many more of the recent games were played as White than as Black
+func (h ColorHistory) RecentBalance() int {
+	balance := 0
+	for _, c := range h.Recent {
+		if c == 'w' {
+			balance++
+		} else {
+			balance--
+		}
+	}
+	return balance
+}
+
+// Balance is how many more games were played as White than as Black
+func (h ColorHistory) Balance() int {
+	return h.White - h.Black
+}
+
+// colorHistoryLocked returns the player's color history: from their account,
+// or from this connection for guests. Callers must hold gm.mu.
+func (gm *GameManager) colorHistoryLocked(p *Player) ColorHistory {
+	if p.Identity == "" {
+		return gm.guestColors[p.ID]
+	}
+	account, err := gm.accounts.Get(p.Identity)
+	if err != nil {
+		return ColorHistory{}
+	}
+	return account.Colors
+}
+
+// assignColorsLocked picks colors for a game between a and b. a's explicit
+// preference of ColorWhite or ColorBlack wins; otherwise whoever has had
+// White more often, recently and then overall, gets Black, and a coin flip
+// decides when they're even. Callers must hold gm.mu.
+func (gm *GameManager) assignColorsLocked(a, b *Player, preference string) (white, black *Player) {
+	switch preference {
+	case ColorWhite:
+		return a, b
+	case ColorBlack:
+		return b, a
+	}
+
+	ha, hb := gm.colorHistoryLocked(a), gm.colorHistoryLocked(b)
+	switch {
+	case ha.RecentBalance() > hb.RecentBalance():
+		return b, a
+	case ha.RecentBalance() < hb.RecentBalance():
+		return a, b
+	case ha.Balance() > hb.Balance():
+		return b, a
+	case ha.Balance() < hb.Balance():
+		return a, b
+	}
+	if rand.IntN(2) == 0 {
+		return b, a
+	}
+	return a, b
+}
+
+// recordColorsLocked adds a new game to both players' color histories.
+// Accounts are updated in the background, since that writes the accounts
+// file. Callers must hold gm.mu.
+func (gm *GameManager) recordColorsLocked(white, black *Player) {
+	for _, seat := range []struct {
+		player *Player
+		color  Color
+	}{{white, White}, {black, Black}} {
+		p := seat.player
+		if p.Identity == "" {
+			gm.guestColors[p.ID] = gm.guestColors[p.ID].record(seat.color)
+			continue
+		}
+		go recordAccountColor(gm.accounts, p.Identity, seat.color)
+	}
+}
+
+// recordAccountColor adds a game played as color to an account's color history
+func recordAccountColor(accounts AccountStore, identity string, color Color) {
+	err := accounts.Update(identity, func(a *Account) { a.Colors = a.Colors.record(color) })
+	if err != nil && !errors.Is(err, ErrAccountNotFound) {
+		log.Printf("failed to save color history for %s: %v", identity, err)
+	}
+}
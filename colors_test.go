@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColorHistoryRecord(t *testing.T) {
+	var h ColorHistory
+	for i := range 12 {
+		color := White
+		if i%3 == 0 {
+			color = Black
+		}
+		h = h.record(color)
+	}
+	if h.White != 8 || h.Black != 4 {
+		t.Errorf("counted %d White and %d Black, want 8 and 4", h.White, h.Black)
+	}
+	if want := "wbwwbwwbww"; h.Recent != want {
+		t.Errorf("recent = %q, want the last %d games, %q", h.Recent, recentColorGames, want)
+	}
+	if got := h.RecentBalance(); got != 4 {
+		t.Errorf("recent balance = %d, want 4", got)
+	}
+	if got := h.Balance(); got != 4 {
+		t.Errorf("balance = %d, want 4", got)
+	}
+}
+
+func TestAssignColors(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		a, b       ColorHistory
+		preference string
+		want       string // Who gets White, or "" for either
+	}{{
+		name: "both new",
+		want: "",
+	}, {
+		name: "a has had White more recently",
+		a:    ColorHistory{White: 1, Black: 5, Recent: "ww"},
+		b:    ColorHistory{White: 5, Black: 1, Recent: "bb"},
+		want: "b",
+	}, {
+		name: "b has had White more recently",
+		a:    ColorHistory{Recent: "bwb"},
+		b:    ColorHistory{Recent: "wwb"},
+		want: "a",
+	}, {
+		name: "recently even, a has had White more overall",
+		a:    ColorHistory{White: 20, Black: 10, Recent: "wb"},
+		b:    ColorHistory{White: 10, Black: 10, Recent: "bw"},
+		want: "b",
+	}, {
+		name: "all even",
+		a:    ColorHistory{White: 3, Black: 3, Recent: "wbwbwb"},
+		b:    ColorHistory{White: 1, Black: 1, Recent: "bw"},
+		want: "",
+	}, {
+		name:       "a asks for White",
+		a:          ColorHistory{Recent: "www"},
+		preference: ColorWhite,
+		want:       "a",
+	}, {
+		name:       "a asks for Black",
+		b:          ColorHistory{Recent: "bbb"},
+		preference: ColorBlack,
+		want:       "b",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			gm := newGameManager()
+			a := &Player{ID: "player_a", Name: "a"}
+			b := &Player{ID: "player_b", Name: "b"}
+			gm.guestColors[a.ID], gm.guestColors[b.ID] = tt.a, tt.b
+
+			seen := make(map[string]bool)
+			for range 100 {
+				white, _ := gm.assignColorsLocked(a, b, tt.preference)
+				seen[white.Name] = true
+			}
+			if tt.want == "" {
+				if !seen["a"] || !seen["b"] {
+					t.Errorf("White always went to the same player, want a coin flip")
+				}
+				return
+			}
+			if len(seen) != 1 || !seen[tt.want] {
+				t.Errorf("White went to %v, want only %s", seen, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignColorsFromAccounts(t *testing.T) {
+	gm := newGameManager()
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := claimUsername(gm.accounts, name, "SHA256:"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice := &Player{ID: "player_alice", Name: "alice", Identity: "alice"}
+	bob := &Player{ID: "player_bob", Name: "bob", Identity: "bob"}
+
+	// The accounts are saved in the background
+	gm.recordColorsLocked(alice, bob)
+	deadline := time.Now().Add(time.Second)
+	for {
+		a, _ := gm.accounts.Get("alice")
+		b, _ := gm.accounts.Get("bob")
+		if a.Colors.Recent == "w" && b.Colors.Recent == "b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("color histories are %+v and %+v, want one game as White and Black", a.Colors, b.Colors)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// alice had White, so now bob gets it whoever asks
+	for range 20 {
+		if white, _ := gm.assignColorsLocked(alice, bob, ColorRandom); white != bob {
+			t.Fatal("alice got White twice in a row")
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// textCommand handles a non-interactive SSH command like `ssh chessh.imjasonh.dev whoami`
+type textCommand struct {
+	usage string
+	run   func(s ssh.Session, args []string) error
+}
+
+var textCommands = map[string]textCommand{
+	"whoami": {
+		usage: "whoami                 show the identity bound to your key",
+		run:   runWhoami,
+	},
+	"claim": {
+		usage: "claim [name]           bind a username to your key (defaults to your SSH user)",
+		run:   runClaim,
+	},
+	"addkey": {
+		usage: "addkey <public key>    bind another public key to your account",
+		run:   runAddKey,
+	},
+	"profile": {
+		usage: "profile [name]         show a player's ratings and record (defaults to yours)",
+		run:   runProfile,
+	},
+	"leaderboard": {
+		usage: "leaderboard [pool]     show the top rated players, e.g. blitz or threecheck",
+		run:   runLeaderboard,
+	},
+}
+
+// interactiveCommands start the game with an action instead of printing text;
+// they need a terminal, e.g. `ssh -t chessh.imjasonh.dev challenge alice`
+var interactiveCommands = map[string]string{
+	"challenge": "challenge <user> [5+3] [white|black|random] [variant] [rated|casual]\n" +
+		"                         challenge an online player (needs ssh -t)",
+	"room": "room [white|black|random] [5+3] [variant] [rated] [fen <FEN>]\n" +
+		"                         open a private room and get a join code (needs ssh -t)",
+	"join":  "join <code>            join a private room (needs ssh -t)",
+	"watch": "watch <user|code>      watch a live game (needs ssh -t)",
+	"accessible": "accessible [command]   play in plain text, e.g. with a screen reader; used whenever there's\n" +
+		"                         no terminal, so `ssh -T` works too",
+	"tournament": "tournament swiss [rounds] | roundrobin | arena [minutes] [5+3] [variant] [rated|casual]\n" +
+		"                         organize a tournament others can join from the lobby (needs ssh -t)",
+}
+
+func init() {
+	// Registered here since runHelp reads textCommands
+	textCommands["help"] = textCommand{
+		usage: "help                   show this help",
+		run:   runHelp,
+	}
+}
+
+// commandMiddleware runs text commands and passes plain sessions on to the game
+func commandMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			args := s.Command()
+			_, _, terminal := s.Pty()
+			if len(args) > 0 && args[0] == "accessible" {
+				if terminal {
+					next(s)
+				} else {
+					runAccessible(s, args[1:])
+				}
+				return
+			}
+
+			if len(args) == 0 || interactiveCommands[args[0]] != "" {
+				// Without a terminal the game is played in plain text
+				if !terminal {
+					runAccessible(s, args)
+					return
+				}
+				next(s)
+				return
+			}
+
+			cmd, ok := textCommands[args[0]]
+			if !ok {
+				wish.Errorf(s, "unknown command %q\n\n", args[0])
+				_ = runHelp(s, nil)
+				_ = s.Exit(1)
+				return
+			}
+			if err := cmd.run(s, args[1:]); err != nil {
+				wish.Fatalln(s, err)
+			}
+		}
+	}
+}
+
+func runWhoami(s ssh.Session, args []string) error {
+	name, identity, fingerprint := resolveIdentity(s)
+	if fingerprint == "" {
+		wish.Printf(s, "You are %s: connect with a public key to claim a username.\n", name)
+		return nil
+	}
+	if identity == "" {
+		wish.Printf(s, "You are %s. Key %s is not bound to an account; run `claim` to register.\n", name, fingerprint)
+		return nil
+	}
+
+	account, err := GetGameManager().Accounts().Get(identity)
+	if err != nil {
+		return err
+	}
+	wish.Printf(s, "You are %s (registered %s)\n", account.Username, account.Created.Format("2006-01-02"))
+	wish.Println(s, "Keys:")
+	for _, key := range account.Keys {
+		marker := " "
+		if key == fingerprint {
+			marker = "*"
+		}
+		wish.Printf(s, " %s %s\n", marker, key)
+	}
+	return nil
+}
+
+func runClaim(s ssh.Session, args []string) error {
+	username := s.User()
+	if len(args) > 0 {
+		username = args[0]
+	}
+
+	account, err := claimUsername(GetGameManager().Accounts(), strings.ToLower(username), sessionFingerprint(s))
+	if err != nil {
+		return err
+	}
+	wish.Printf(s, "Username %q is now bound to your key.\n", account.Username)
+	return nil
+}
+
+func runAddKey(s ssh.Session, args []string) error {
+	_, identity, _ := resolveIdentity(s)
+	if identity == "" {
+		return fmt.Errorf("you must claim a username before adding keys")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: addkey <public key>")
+	}
+
+	fingerprint, err := addAccountKey(GetGameManager().Accounts(), identity, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	wish.Printf(s, "Added key %s to %s.\n", fingerprint, identity)
+	return nil
+}
+
+func runProfile(s ssh.Session, args []string) error {
+	username := ""
+	if len(args) > 0 {
+		username = args[0]
+	} else if _, username, _ = resolveIdentity(s); username == "" {
+		return fmt.Errorf("usage: profile <name>")
+	}
+
+	gm := GetGameManager()
+	profile, err := buildProfile(gm.Accounts(), gm.Games(), username)
+	if err != nil {
+		return err
+	}
+	wish.Print(s, profile)
+	return nil
+}
+
+func runLeaderboard(s ssh.Session, args []string) error {
+	pools := ratingPools()
+	size := 10
+	if len(args) > 0 {
+		pool, err := parsePool(args[0])
+		if err != nil {
+			return err
+		}
+		pools, size = []string{pool}, leaderboardSize
+	}
+
+	for i, pool := range pools {
+		entries, err := leaderboard(GetGameManager().Accounts(), pool, size)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			wish.Println(s, "")
+		}
+		wish.Print(s, leaderboardText(pool, entries))
+	}
+	return nil
+}
+
+func runHelp(s ssh.Session, args []string) error {
+	usages := make(map[string]string)
+	for name, cmd := range textCommands {
+		usages[name] = cmd.usage
+	}
+	for name, usage := range interactiveCommands {
+		usages[name] = usage
+	}
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	wish.Println(s, "Usage: ssh chessh.imjasonh.dev [command]")
+	wish.Println(s, "")
+	wish.Println(s, "With no command, you join the game.")
+	wish.Println(s, "")
+	wish.Println(s, "Commands:")
+	for _, name := range names {
+		wish.Printf(s, "  %s\n", usages[name])
+	}
+	return nil
+}
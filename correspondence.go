@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errCorrespondenceGuest is returned when a guest tries to start a correspondence game
+var errCorrespondenceGuest = errors.New("correspondence games need a claimed username, so you can come back to them")
+
+// checkCorrespondence makes sure everyone in a correspondence game can find
+// it again after logging off
+func checkCorrespondence(settings GameSettings, players ...*Player) error {
+	if !settings.TimeControl.Correspondence() {
+		return nil
+	}
+	for _, p := range players {
+		if p.Identity == "" {
+			return errCorrespondenceGuest
+		}
+	}
+	return nil
+}
+
+// resultMissing reports whether the game is still in progress
+func (gs *GameSession) resultMissing() bool {
+	result, _ := gs.GetResult()
+	return result == nil
+}
+
+// waitingForPlayers reports whether the game should be kept even though
+// nobody is connected to it
+func (gs *GameSession) waitingForPlayers() bool {
+	return gs.TimeControl.Correspondence() && gs.resultMissing()
+}
+
+// detach takes a player out of their seat without ending the game
+func (gs *GameSession) detach(playerID string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	if gs.White != nil && gs.White.ID == playerID {
+		gs.White = detachedPlayer(gs.White)
+	}
+	if gs.Black != nil && gs.Black.ID == playerID {
+		gs.Black = detachedPlayer(gs.Black)
+	}
+}
+
+// attach seats a returning player in the empty seat that belongs to their identity
+func (gs *GameSession) attach(player *Player) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for _, seat := range []**Player{&gs.White, &gs.Black} {
+		if (*seat).Identity == player.Identity && !(*seat).Connected {
+			player.Color = (*seat).Color
+			player.GameID = gs.ID
+			*seat = player
+			return nil
+		}
+	}
+	return errors.New("you already have this game open")
+}
+
+// saveOngoing stores the unfinished game so it survives a restart. Each save
+// writes the game as it is when the save runs, and nothing once it's over.
+func (gs *GameSession) saveOngoing() {
+	if gs.ongoing == nil {
+		return
+	}
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+	if !gs.resultMissing() {
+		return
+	}
+	if err := gs.ongoing.Save(gs.record()); err != nil {
+		log.Printf("failed to save correspondence game %s: %v", gs.ID, err)
+	}
+}
+
+// SetCorrespondenceStore replaces the store unfinished correspondence games
+// are kept in, and resumes the games already in it
+func (gm *GameManager) SetCorrespondenceStore(ongoing GameStore) error {
+	records, err := ongoing.List("")
+	if err != nil {
+		return err
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	gm.ongoing = ongoing
+	for _, record := range records {
+		session, err := gm.resumeLocked(record)
+		if err != nil {
+			log.Printf("failed to resume correspondence game %s: %v", record.ID, err)
+			continue
+		}
+		gm.activeGames[session.ID] = session
+	}
+	return nil
+}
+
+// resumeLocked recreates a correspondence game from storage with both seats
+// empty. Callers must hold gm.mu.
+func (gm *GameManager) resumeLocked(record *GameRecord) (*GameSession, error) {
+	seat := func(p RecordedPlayer) *Player {
+		return &Player{ID: fmt.Sprintf("offline_%s_%s", record.ID, p.Identity), Name: p.Name, Identity: p.Identity}
+	}
+	settings := GameSettings{
+		TimeControl: record.TimeControl,
+		Variant:     record.Variant,
+		Rated:       record.Rated,
+		FEN:         record.FEN,
+		Private:     record.Private,
+	}
+	session := NewGameSession(record.ID, seat(record.White), seat(record.Black), settings, gm.accounts, gm.games, gm.ongoing)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.Started = record.Started
+	session.RecordID = record.ID
+	lastMove := record.Started
+	for _, move := range record.Moves {
+		from, to, err := parseCoordinateMove(move.Move)
+		if err != nil {
+			return nil, err
+		}
+		if !session.Game.MakeMove(from, to) {
+			return nil, fmt.Errorf("stored move %s is illegal", move.Move)
+		}
+		session.moveTimes = append(session.moveTimes, move.Time)
+		lastMove = move.Time
+	}
+	session.Clock.Start(session.Game.CurrentTurn, lastMove)
+	return session, nil
+}
+
+// CorrespondenceGame summarizes one of a player's correspondence games for the lobby
+type CorrespondenceGame struct {
+	ID       string
+	Opponent string
+	YourMove bool
+	Moves    int
+	Deadline time.Time // When the side to move runs out of time
+	Settings GameSettings
+}
+
+// CorrespondenceGames lists the identity's unfinished correspondence games,
+// the ones waiting for their move first
+func (gm *GameManager) CorrespondenceGames(identity string) []CorrespondenceGame {
+	if identity == "" {
+		return nil
+	}
+
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	var games []CorrespondenceGame
+	for _, session := range gm.activeGames {
+		if !session.waitingForPlayers() {
+			continue
+		}
+		session.mu.RLock()
+		white, black := session.White, session.Black
+		if white.Identity == identity || black.Identity == identity {
+			me, opponent := white, black
+			if black.Identity == identity && white.Identity != identity {
+				me, opponent = black, white
+			}
+			remaining := session.Clock.RemainingAt(session.Game.CurrentTurn, time.Now())
+			games = append(games, CorrespondenceGame{
+				ID:       session.ID,
+				Opponent: opponent.Name,
+				YourMove: session.Game.CurrentTurn == me.Color,
+				Moves:    len(session.Game.MoveHistory),
+				Deadline: time.Now().Add(remaining),
+				Settings: session.GameSettings,
+			})
+		}
+		session.mu.RUnlock()
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].YourMove != games[j].YourMove {
+			return games[i].YourMove
+		}
+		return games[i].Deadline.Before(games[j].Deadline)
+	})
+	return games
+}
+
+// OpenCorrespondence seats the player in one of their correspondence games,
+// leaving whatever they were doing
+func (gm *GameManager) OpenCorrespondence(gameID string, player *Player) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	session, exists := gm.activeGames[gameID]
+	if !exists || !session.waitingForPlayers() {
+		return errors.New("that game is over")
+	}
+	if gm.inGameLocked(player.ID) {
+		return errors.New("finish your current game first")
+	}
+
+	gm.stopWatchingLocked(player.ID)
+	gm.removeFromQueueLocked(player.ID)
+	gm.closeRoomsLocked(player.ID)
+	gm.leaveGameLocked(player.ID)
+	if err := session.attach(player); err != nil {
+		return err
+	}
+	gm.playerToGame[player.ID] = gameID
+
+	notifyPlayer(player, GameUpdate{Type: "matched", Data: map[string]any{"gameID": gameID}})
+	return nil
+}
+
+// dropFinishedCorrespondenceLocked forgets correspondence games that ended,
+// e.g. on time, while nobody was connected. Callers must hold gm.mu.
+func (gm *GameManager) dropFinishedCorrespondenceLocked() {
+	for id, session := range gm.activeGames {
+		if session.TimeControl.Correspondence() && !session.resultMissing() && session.Abandoned() {
+			delete(gm.activeGames, id)
+			gm.expireRoomCodesLocked(id)
+			session.cleanup()
+		}
+	}
+}
+
+// correspondenceLines shows the player's correspondence games in the lobby
+func (m model) correspondenceLines(cursor func() string) string {
+	if len(m.correspondence) == 0 {
+		return ""
+	}
+
+	var s strings.Builder
+	s.WriteString("Your correspondence games\n")
+	for _, game := range m.correspondence {
+		turn := "their move"
+		if game.YourMove {
+			turn = "YOUR MOVE"
+		}
+		s.WriteString(fmt.Sprintf("%svs %-20s %s, %d moves, %s, %s left\n", cursor(), game.Opponent,
+			game.Settings.TimeControl, game.Moves, turn, formatClock(time.Until(game.Deadline))))
+	}
+	s.WriteString("\n")
+	return s.String()
+}
+
+// yourMoveCount is how many correspondence games are waiting for the player
+func (m model) yourMoveCount() int {
+	count := 0
+	for _, game := range m.correspondence {
+		if game.YourMove {
+			count++
+		}
+	}
+	return count
+}
+
+// openCorrespondence sits the player back down at one of their correspondence games
+func (m model) openCorrespondence(gameID string) model {
+	if err := GetGameManager().OpenCorrespondence(gameID, m.player); err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.notice = ""
+	return m
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// connectTestMember connects a player with an account, as correspondence games need
+func connectTestMember(gm *GameManager, name string) *Player {
+	p := &Player{ID: "player_" + name, Name: name, Identity: name, Connected: true, UpdateChan: make(chan GameUpdate, 100)}
+	gm.Connect(p)
+	return p
+}
+
+// restartTestServer starts a new game manager on the correspondence games in dir
+func restartTestServer(t *testing.T, dir string) *GameManager {
+	t.Helper()
+	ongoing, err := NewFileGameStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm := newGameManager()
+	if err := gm.SetCorrespondenceStore(ongoing); err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+func TestCorrespondenceResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	gm := restartTestServer(t, dir)
+	alice, bob := connectTestMember(gm, "alice"), connectTestMember(gm, "bob")
+	gm.mu.Lock()
+	session := gm.startGameLocked(alice, bob, GameSettings{TimeControl: TimeControl{Days: 3}})
+	gm.mu.Unlock()
+	for _, move := range []struct {
+		player *Player
+		move   string
+	}{{alice, "e2e4"}, {bob, "e7e5"}} {
+		from, to, _ := parseCoordinateMove(move.move)
+		if !session.MakeMove(move.player.ID, from, to) {
+			t.Fatalf("%s isn't legal", move.move)
+		}
+	}
+
+	gm = restartTestServer(t, dir)
+	for _, tt := range []struct {
+		identity string
+		yourMove bool
+	}{{"alice", true}, {"bob", false}} {
+		games := gm.CorrespondenceGames(tt.identity)
+		if len(games) != 1 {
+			t.Fatalf("%s has %d correspondence games after the restart, want 1", tt.identity, len(games))
+		}
+		g := games[0]
+		if g.Moves != 2 || g.YourMove != tt.yourMove {
+			t.Errorf("%s's game has %d moves, their move %v; want 2, %v", tt.identity, g.Moves, g.YourMove, tt.yourMove)
+		}
+		if left := time.Until(g.Deadline); left < 3*24*time.Hour-time.Minute || left > 3*24*time.Hour {
+			t.Errorf("%s's game has %v left on the clock, want about 3 days", tt.identity, left)
+		}
+	}
+
+	alice = connectTestMember(gm, "alice")
+	id := gm.CorrespondenceGames("alice")[0].ID
+	if err := gm.OpenCorrespondence(id, alice); err != nil {
+		t.Fatal(err)
+	}
+	from, to, _ := parseCoordinateMove("g1f3")
+	if !gm.GetGameSession(alice.ID).MakeMove(alice.ID, from, to) {
+		t.Error("alice can't play on in the resumed game")
+	}
+}
+
+func TestCorrespondenceFlagsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ongoing, err := NewFileGameStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	record := &GameRecord{
+		Schema:      currentGameSchema,
+		ID:          "game_overdue",
+		White:       RecordedPlayer{Name: "alice", Identity: "alice"},
+		Black:       RecordedPlayer{Name: "bob", Identity: "bob"},
+		TimeControl: TimeControl{Days: 1},
+		Moves: []RecordedMove{
+			{Move: "e2e4", Time: now.Add(-72 * time.Hour)},
+			{Move: "e7e5", Time: now.Add(-48 * time.Hour)},
+		},
+		Started: now.Add(-72 * time.Hour),
+	}
+	if err := ongoing.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// White let a day pass without moving while the server was down
+	gm := restartTestServer(t, dir)
+	gm.mu.RLock()
+	session := gm.activeGames[record.ID]
+	gm.mu.RUnlock()
+	if session == nil {
+		t.Fatal("the game wasn't resumed")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := gm.ongoing.Get(record.ID)
+		if _, stored := gm.games.Get(record.ID); stored == nil && errors.Is(err, ErrGameNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the overdue game wasn't moved from the correspondence store to the finished games")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if result, _ := session.GetResult(); result == nil || *result != (GameResult{Outcome: BlackWins, Reason: ReasonTimeout}) {
+		t.Errorf("result = %v, want Black winning on time", result)
+	}
+	if games := gm.CorrespondenceGames("alice"); len(games) != 0 {
+		t.Errorf("alice still has %d correspondence games", len(games))
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrGameNotFound is returned when no stored game has the requested ID
+var ErrGameNotFound = errors.New("game not found")
+
+// currentGameSchema is the GameRecord format Save writes. To change the
+// format, bump it and add a migration from the previous version to
+// gameMigrations; older records are upgraded when they're loaded.
+const currentGameSchema = 1
+
+// gameMigrations upgrade a decoded record from the version it's keyed by to
+// the next one
+var gameMigrations = map[int]func(record map[string]any) error{}
+
+// RecordedPlayer is one side of a stored game
+type RecordedPlayer struct {
+	Name     string `json:"name"`
+	Identity string `json:"identity,omitempty"` // Empty for guests
+}
+
+// RecordedMove is a move in coordinate notation, like "e2e4", and when it was played
+type RecordedMove struct {
+	Move string    `json:"move"`
+	Time time.Time `json:"time"`
+}
+
+// GameRecord is a finished game as it's stored
+type GameRecord struct {
+	Schema        int              `json:"schema"`
+	ID            string           `json:"id"`
+	White         RecordedPlayer   `json:"white"`
+	Black         RecordedPlayer   `json:"black"`
+	TimeControl   TimeControl      `json:"timeControl"`
+	Variant       Variant          `json:"variant"`
+	Rated         bool             `json:"rated"`
+	FEN           string           `json:"fen,omitempty"` // Starting position, empty for the standard one
+	Private       bool             `json:"private,omitempty"`
+	Moves         []RecordedMove   `json:"moves"`
+	Outcome       Outcome          `json:"outcome"`
+	Reason        string           `json:"reason"`
+	RatingChanges *[2]RatingChange `json:"ratingChanges,omitempty"`
+	Started       time.Time        `json:"started"`
+	Ended         time.Time        `json:"ended"`
+}
+
+// Result returns how the game ended
+func (r *GameRecord) Result() GameResult {
+	return GameResult{Outcome: r.Outcome, Reason: r.Reason}
+}
+
+// GameStore keeps finished games so they can be looked at later
+type GameStore interface {
+	Save(record *GameRecord) error
+	Get(id string) (*GameRecord, error)
+	// List returns the games the identity played, newest first. An empty
+	// identity lists every game.
+	List(identity string) ([]*GameRecord, error)
+	Delete(id string) error
+	// DeleteBefore removes games that ended before cutoff, returning how many
+	DeleteBefore(cutoff time.Time) (int, error)
+}
+
+// FileGameStore keeps each game in its own JSON file in a directory.
+// An empty directory keeps games in memory only.
+type FileGameStore struct {
+	dir   string
+	games map[string]*GameRecord
+	mu    sync.RWMutex
+}
+
+func NewFileGameStore(dir string) (*FileGameStore, error) {
+	store := &FileGameStore{
+		dir:   dir,
+		games: make(map[string]*GameRecord),
+	}
+	if dir == "" {
+		return store, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read games: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		record, err := store.load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// One bad file shouldn't keep the server from starting
+			log.Printf("skipping stored game %s: %v", entry.Name(), err)
+			continue
+		}
+		store.games[record.ID] = record
+	}
+	return store, nil
+}
+
+// load reads a stored game, upgrading and rewriting it if it was saved in an
+// older format
+func (s *FileGameStore) load(path string) (*GameRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	schema, _ := raw["schema"].(float64)
+	version := int(schema)
+	if version > currentGameSchema {
+		return nil, fmt.Errorf("schema version %d is newer than this server supports (%d)", version, currentGameSchema)
+	}
+	migrated := version < currentGameSchema
+	for ; version < currentGameSchema; version++ {
+		if migrate, ok := gameMigrations[version]; ok {
+			if err := migrate(raw); err != nil {
+				return nil, fmt.Errorf("failed to migrate from schema version %d: %w", version, err)
+			}
+		}
+	}
+	raw["schema"] = currentGameSchema
+
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var record GameRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.ID == "" {
+		return nil, errors.New("missing game ID")
+	}
+	if migrated {
+		if err := s.write(&record); err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+func (s *FileGameStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// write saves one game to disk
+func (s *FileGameStore) write(record *GameRecord) error {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode game: %w", err)
+	}
+	return writeFileAtomic(s.path(record.ID), data)
+}
+
+func (s *FileGameStore) Save(record *GameRecord) error {
+	if record.ID == "" || strings.ContainsAny(record.ID, `/\`) {
+		return fmt.Errorf("invalid game ID %q", record.ID)
+	}
+	record = copyGameRecord(record)
+	record.Schema = currentGameSchema
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games[record.ID] = record
+	return s.write(record)
+}
+
+func (s *FileGameStore) Get(id string) (*GameRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.games[id]
+	if !exists {
+		return nil, ErrGameNotFound
+	}
+	return copyGameRecord(record), nil
+}
+
+func (s *FileGameStore) List(identity string) ([]*GameRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*GameRecord
+	for _, record := range s.games {
+		if identity == "" || record.White.Identity == identity || record.Black.Identity == identity {
+			records = append(records, copyGameRecord(record))
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Ended.After(records[j].Ended)
+	})
+	return records, nil
+}
+
+func (s *FileGameStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.games[id]; !exists {
+		return ErrGameNotFound
+	}
+	if s.dir != "" {
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete game %s: %w", id, err)
+		}
+	}
+	delete(s.games, id)
+	return nil
+}
+
+func (s *FileGameStore) DeleteBefore(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, record := range s.games {
+		if !record.Ended.Before(cutoff) {
+			continue
+		}
+		if s.dir != "" {
+			if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return deleted, fmt.Errorf("failed to delete game %s: %w", id, err)
+			}
+		}
+		delete(s.games, id)
+		deleted++
+	}
+	return deleted, nil
+}
+
+func copyGameRecord(record *GameRecord) *GameRecord {
+	// Round-trip through JSON so the moves aren't shared
+	data, _ := json.Marshal(record)
+	var c GameRecord
+	_ = json.Unmarshal(data, &c)
+	return &c
+}
+
+// pruneGames deletes games older than retention once a day, until the
+// server stops. A retention of zero keeps games forever.
+func pruneGames(store GameStore, retention time.Duration, done <-chan struct{}) {
+	if retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.DeleteBefore(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to prune stored games: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d games older than %s", deleted, retention)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGameStoreMigrations(t *testing.T) {
+	// Version 0 called the reason for the result "termination"
+	renameTermination := map[int]func(record map[string]any) error{
+		0: func(record map[string]any) error {
+			record["reason"] = record["termination"]
+			delete(record, "termination")
+			return nil
+		},
+	}
+	failing := map[int]func(record map[string]any) error{
+		0: func(record map[string]any) error { return errors.New("can't") },
+	}
+
+	for _, tt := range []struct {
+		name       string
+		stored     string
+		migrations map[int]func(record map[string]any) error
+		wantReason string // Empty if the game shouldn't load
+		rewritten  bool
+	}{{
+		name:       "current schema",
+		stored:     `{"schema": 1, "id": "g1", "reason": "checkmate"}`,
+		migrations: renameTermination,
+		wantReason: "checkmate",
+	}, {
+		name:       "migrated from version 0",
+		stored:     `{"id": "g1", "termination": "checkmate"}`,
+		migrations: renameTermination,
+		wantReason: "checkmate",
+		rewritten:  true,
+	}, {
+		name:       "version 0 with nothing to migrate",
+		stored:     `{"schema": 0, "id": "g1", "reason": "resignation"}`,
+		wantReason: "resignation",
+		rewritten:  true,
+	}, {
+		name:       "failed migration",
+		stored:     `{"id": "g1", "termination": "checkmate"}`,
+		migrations: failing,
+	}, {
+		name:   "newer schema",
+		stored: `{"schema": 2, "id": "g1", "reason": "checkmate"}`,
+	}, {
+		name:   "missing ID",
+		stored: `{"schema": 1, "reason": "checkmate"}`,
+	}, {
+		name:   "not JSON",
+		stored: `{"schema": 1,`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := gameMigrations
+			gameMigrations = tt.migrations
+			defer func() { gameMigrations = saved }()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "g1.json")
+			if err := os.WriteFile(path, []byte(tt.stored), 0600); err != nil {
+				t.Fatal(err)
+			}
+			store, err := NewFileGameStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := store.Get("g1")
+			if tt.wantReason == "" {
+				if err == nil {
+					t.Fatalf("loaded %+v, want the game skipped", record)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Reason != tt.wantReason || record.Schema != currentGameSchema {
+				t.Errorf("loaded reason %q, schema %d; want %q, %d", record.Reason, record.Schema, tt.wantReason, currentGameSchema)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rewritten := string(data) != tt.stored; rewritten != tt.rewritten {
+				t.Errorf("file rewritten = %v, want %v", rewritten, tt.rewritten)
+			}
+			if tt.rewritten {
+				var onDisk GameRecord
+				if err := json.Unmarshal(data, &onDisk); err != nil {
+					t.Fatal(err)
+				}
+				if onDisk.Reason != tt.wantReason || onDisk.Schema != currentGameSchema {
+					t.Errorf("rewrote reason %q, schema %d; want %q, %d", onDisk.Reason, onDisk.Schema, tt.wantReason, currentGameSchema)
+				}
+			}
+		})
+	}
+}
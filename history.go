@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyPageSize is how many games the "My games" screen shows at once
+const historyPageSize = 15
+
+// replayState steps through a stored game
+type replayState struct {
+	record    *GameRecord
+	positions []*Game // The position before the first move, then after each move
+	ply       int     // Index into positions
+}
+
+// newReplay plays through a stored game, keeping every position
+func newReplay(record *GameRecord) (*replayState, error) {
+	game := NewVariantGame(record.Variant)
+	if record.FEN != "" {
+		var err error
+		if game, err = NewGameFromFEN(record.FEN); err != nil {
+			return nil, err
+		}
+		game.Variant = record.Variant
+	}
+
+	replay := &replayState{record: record, positions: []*Game{game.Clone()}}
+	for _, move := range record.Moves {
+		from, to, err := parseCoordinateMove(move.Move)
+		if err != nil {
+			return nil, err
+		}
+		if !game.MakeMove(from, to) {
+			return nil, fmt.Errorf("stored move %s is illegal", move.Move)
+		}
+		replay.positions = append(replay.positions, game.Clone())
+	}
+	return replay, nil
+}
+
+// recordOpening names the stored game's opening, if it started from the usual position
+func recordOpening(record *GameRecord) string {
+	if record.FEN != "" || record.Variant != Standard {
+		return ""
+	}
+	moves := make([]string, len(record.Moves))
+	for i, move := range record.Moves {
+		moves[i] = move.Move
+	}
+	return openingName(moves)
+}
+
+// recordOutcome returns the color the identity played in a stored game, and
+// whether they "Won", "Lost" or "Drew"
+func recordOutcome(record *GameRecord, identity string) (Color, string) {
+	color := White
+	if record.Black.Identity == identity {
+		color = Black
+	}
+	outcome := "Drew"
+	if winner, decisive := record.Result().Winner(); decisive {
+		outcome = "Lost"
+		if winner == color {
+			outcome = "Won"
+		}
+	}
+	return color, outcome
+}
+
+// recordSummary describes a stored game on one line from the identity's point of view
+func recordSummary(record *GameRecord, identity string) string {
+	color, outcome := recordOutcome(record, identity)
+	opponent := record.Black
+	if color == Black {
+		opponent = record.White
+	}
+	summary := fmt.Sprintf("%s  %-5s vs %-20s %-4s by %-16s %s %s", record.Ended.Local().Format("2006-01-02"),
+		color, opponent.Name, outcome, record.Reason, record.TimeControl, record.Variant.Title())
+	if opening := recordOpening(record); opening != "" {
+		summary += ", " + opening
+	}
+	return summary
+}
+
+// showHistory opens the "My games" screen
+func (m model) showHistory() model {
+	if m.player.Identity == "" {
+		m.notice = "Only games played with a claimed username are kept; see `ssh <host> claim <username>`."
+		return m
+	}
+	records, err := GetGameManager().Games().List(m.player.Identity)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.gameState = "history"
+	m.history = records
+	m.historyCursor = 0
+	m.notice = ""
+	return m
+}
+
+// handleHistoryKey moves through the list of past games and opens one
+func (m model) handleHistoryKey(msg tea.KeyMsg) model {
+	switch msg.String() {
+	case "up", "k":
+		if m.historyCursor > 0 {
+			m.historyCursor--
+		}
+	case "down", "j":
+		if m.historyCursor < len(m.history)-1 {
+			m.historyCursor++
+		}
+	case "enter", " ":
+		if m.historyCursor < len(m.history) {
+			replay, err := newReplay(m.history[m.historyCursor])
+			if err != nil {
+				m.notice = err.Error()
+				return m
+			}
+			m.gameState = "replay"
+			m.replay = replay
+			m.game = replay.positions[0]
+			m.notice = ""
+		}
+	case "x", "esc":
+		return m.enterLobby()
+	}
+	return m
+}
+
+// handleReplayKey steps through the replayed game
+func (m model) handleReplayKey(msg tea.KeyMsg) model {
+	r := m.replay
+	switch msg.String() {
+	case "left", "h":
+		if r.ply > 0 {
+			r.ply--
+		}
+	case "right", "l":
+		if r.ply < len(r.positions)-1 {
+			r.ply++
+		}
+	case "home":
+		r.ply = 0
+	case "end":
+		r.ply = len(r.positions) - 1
+	case "f":
+		m.flipped = !m.flipped
+	case "x", "esc":
+		m.gameState = "history"
+		m.replay = nil
+		m.game = NewGame()
+		return m
+	}
+	m.game = r.positions[r.ply]
+	return m
+}
+
+// historyView lists the player's past games
+func (m model) historyView() string {
+	var s strings.Builder
+	s.WriteString("CheSSH: my games\n")
+	s.WriteString("Up/down to select, Enter to replay, X to return to the lobby, Q to quit\n\n")
+	if m.notice != "" {
+		s.WriteString(m.notice + "\n\n")
+	}
+	if len(m.history) == 0 {
+		s.WriteString("You haven't finished any games yet.\n")
+		return s.String()
+	}
+
+	// Scroll so the selected game stays on screen
+	start := max(0, m.historyCursor-historyPageSize+1)
+	end := min(len(m.history), start+historyPageSize)
+	for i := start; i < end; i++ {
+		record := m.history[i]
+		cursor := "  "
+		if i == m.historyCursor {
+			cursor = "> "
+		}
+		s.WriteString(cursor + recordSummary(record, m.player.Identity) + "\n")
+	}
+	if len(m.history) > historyPageSize {
+		s.WriteString(fmt.Sprintf("\n%d of %d games\n", m.historyCursor+1, len(m.history)))
+	}
+	return s.String()
+}
+
+// replayView describes the replayed game above the board
+func (m model) replayView() string {
+	r := m.replay
+	record := r.record
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("Replay: %s (White) vs %s (Black), %s %s, %s\n", record.White.Name, record.Black.Name,
+		record.TimeControl, record.Variant.Title(), record.Ended.Local().Format("2006-01-02 15:04")))
+	if opening := recordOpening(record); opening != "" {
+		s.WriteString(opening + "\n")
+	}
+	if r.ply == 0 {
+		s.WriteString(fmt.Sprintf("Start (%d moves)\n", len(record.Moves)))
+	} else {
+		move := record.Moves[r.ply-1]
+		s.WriteString(fmt.Sprintf("Move %d of %d: %s\n", r.ply, len(record.Moves), move.Move))
+	}
+	if r.ply == len(r.positions)-1 {
+		s.WriteString(fmt.Sprintf("*** %s ***\n", record.Result()))
+	}
+	s.WriteString("Left/right to step through moves, Home/End to jump, F to flip the board, X to go back, Q to quit\n\n")
+	return s.String()
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyAction is something a key does on the board screens
+type keyAction string
+
+const (
+	actionUp             keyAction = "up"
+	actionDown           keyAction = "down"
+	actionLeft           keyAction = "left"
+	actionRight          keyAction = "right"
+	actionSelect         keyAction = "select"
+	actionDeselect       keyAction = "deselect"
+	actionTypeMove       keyAction = "move"
+	actionResign         keyAction = "resign"
+	actionDraw           keyAction = "draw"
+	actionYes            keyAction = "yes"
+	actionNo             keyAction = "no"
+	actionRematch        keyAction = "rematch"
+	actionNoRematch      keyAction = "norematch"
+	actionChallenge      keyAction = "challenge"
+	actionRoom           keyAction = "room"
+	actionJoin           keyAction = "join"
+	actionWatch          keyAction = "watch"
+	actionFirst          keyAction = "first"
+	actionLast           keyAction = "last"
+	actionLeave          keyAction = "leave"
+	actionFlip           keyAction = "flip"
+	actionChat           keyAction = "chat"
+	actionMute           keyAction = "mute"
+	actionOpponentCursor keyAction = "cursor"
+	actionScrollBack     keyAction = "scrollback"
+	actionScrollForward  keyAction = "scrollforward"
+	actionHelp           keyAction = "help"
+	actionQuit           keyAction = "quit"
+)
+
+// keyScreens is a set of the board screens, since a key can mean different
+// things on different screens, like R, which resigns a game but opens a
+// private room once it's over
+type keyScreens int
+
+const (
+	screenPlaying  keyScreens = 1 << iota
+	screenFinished            // After the game, with rematches
+	screenWaiting             // Seeking a game, watching one, after the opponent left, and puzzles
+	screenReplay              // Stepping through a past game
+
+	screenCursor = screenPlaying | screenWaiting  // Screens with a cursor to move
+	screenLobby  = screenFinished | screenWaiting // Screens with the lobby's challenge and room keys
+	screenBoard  = screenPlaying | screenFinished | screenWaiting | screenReplay
+)
+
+// keyBinding is an action with its default keys and the screens it works
+// on. The first key is the one the key handling looks for.
+type keyBinding struct {
+	action  keyAction
+	keys    []string
+	screens keyScreens
+	help    string
+}
+
+// keyBindings are the actions in the order the help lists them. No two
+// actions on the same screen share a default key.
+var keyBindings = []keyBinding{
+	{actionUp, []string{"up", "k"}, screenCursor, "move the cursor up"},
+	{actionDown, []string{"down", "j"}, screenCursor, "move the cursor down"},
+	{actionLeft, []string{"left", "h"}, screenCursor | screenReplay, "move the cursor left, or step back through a game"},
+	{actionRight, []string{"right", "l"}, screenCursor | screenReplay, "move the cursor right, or step forward"},
+	{actionSelect, []string{" ", "enter"}, screenCursor, "pick up a piece, or put it down"},
+	{actionDeselect, []string{"esc"}, screenCursor, "put the piece back, or cancel premoves"},
+	{actionTypeMove, []string{"/"}, screenPlaying, "type a move, e.g. Nf3"},
+	{actionResign, []string{"r"}, screenPlaying, "resign"},
+	{actionDraw, []string{"d"}, screenPlaying, "offer a draw"},
+	{actionYes, []string{"y"}, screenPlaying | screenLobby, "accept a draw offer or challenge, or confirm resigning"},
+	{actionNo, []string{"n"}, screenPlaying | screenLobby, "decline a draw offer or challenge"},
+	{actionRematch, []string{"a"}, screenFinished, "offer or accept a rematch"},
+	{actionNoRematch, []string{"d"}, screenFinished, "decline a rematch"},
+	{actionChallenge, []string{"c"}, screenLobby, "challenge a player"},
+	{actionRoom, []string{"r"}, screenLobby, "open a private room"},
+	{actionJoin, []string{"J"}, screenLobby, "join a private room"},
+	{actionWatch, []string{"w"}, screenLobby, "watch a player or room"},
+	{actionFirst, []string{"home"}, screenReplay, "go to the start of the game"},
+	{actionLast, []string{"end"}, screenReplay, "go to the end of the game"},
+	{actionLeave, []string{"x"}, screenBoard, "go back to the lobby"},
+	{actionFlip, []string{"f"}, screenBoard, "flip the board"},
+	{actionChat, []string{"t"}, screenBoard, "say something"},
+	{actionMute, []string{"m"}, screenBoard, "mute or unmute the chat"},
+	{actionOpponentCursor, []string{"o"}, screenBoard, "show or hide the opponent's cursor"},
+	{actionScrollBack, []string{"pgup"}, screenBoard, "scroll the move list back"},
+	{actionScrollForward, []string{"pgdown"}, screenBoard, "scroll the move list forward"},
+	{actionHelp, []string{"?"}, screenBoard, "show or hide this help"},
+	{actionQuit, []string{"q"}, screenBoard, "quit"},
+}
+
+// keyNames are the keys that aren't a single character, as players type
+// them when binding keys, and the key messages they stand for
+var keyNames = map[string]tea.KeyType{
+	"up":        tea.KeyUp,
+	"down":      tea.KeyDown,
+	"left":      tea.KeyLeft,
+	"right":     tea.KeyRight,
+	"enter":     tea.KeyEnter,
+	"esc":       tea.KeyEscape,
+	"tab":       tea.KeyTab,
+	"backspace": tea.KeyBackspace,
+	"home":      tea.KeyHome,
+	"end":       tea.KeyEnd,
+	"pgup":      tea.KeyPgUp,
+	"pgdown":    tea.KeyPgDown,
+	" ":         tea.KeySpace,
+}
+
+// keyMsg is the message for pressing a key named as tea.KeyMsg.String names it
+func keyMsg(key string) tea.KeyMsg {
+	if t, ok := keyNames[key]; ok {
+		if t == tea.KeySpace {
+			return tea.KeyMsg{Type: t, Runes: []rune{' '}}
+		}
+		return tea.KeyMsg{Type: t}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// parseKey reads a key as players write it: "space", a special key like
+// "pgup" or a single character
+func parseKey(s string) (string, error) {
+	if len([]rune(s)) == 1 {
+		return s, nil
+	}
+	s = strings.ToLower(s)
+	if s == "space" {
+		return " ", nil
+	}
+	if _, ok := keyNames[s]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown key %q", s)
+}
+
+// keyLabel writes a key for the help
+func keyLabel(key string) string {
+	if key == " " {
+		return "space"
+	}
+	return key
+}
+
+// keymap maps keys to the actions that work on screens: the defaults, then
+// the player's own bindings, which replace an action's default keys and win
+// any clash with another's
+func (m model) keymap(screens keyScreens) map[string]keyAction {
+	keys := make(map[string]keyAction)
+	for _, binding := range keyBindings {
+		if _, ok := m.prefs.Keys[string(binding.action)]; !ok && binding.screens&screens != 0 {
+			for _, key := range binding.keys {
+				keys[key] = binding.action
+			}
+		}
+	}
+	for _, binding := range keyBindings {
+		if binding.screens&screens != 0 {
+			for _, key := range m.prefs.Keys[string(binding.action)] {
+				keys[key] = binding.action
+			}
+		}
+	}
+	return keys
+}
+
+// translateKey turns a key the player pressed into the first default key of
+// its action on the current screen, so the key handling only has to know the
+// defaults. It reports false for a default key the player has taken away
+// from its action.
+func (m model) translateKey(msg tea.KeyMsg) (tea.KeyMsg, bool) {
+	screen := m.keyScreen()
+	key := msg.String()
+	if action, ok := m.keymap(screen)[key]; ok {
+		for _, binding := range keyBindings {
+			if binding.action == action {
+				return keyMsg(binding.keys[0]), true
+			}
+		}
+	}
+	for _, binding := range keyBindings {
+		if binding.screens&screen != 0 && slices.Contains(binding.keys, key) {
+			return msg, false
+		}
+	}
+	return msg, true
+}
+
+// keyScreen is the board screen the player is on, or 0 for the lobby and its
+// lists, where the player's key bindings don't apply
+func (m model) keyScreen() keyScreens {
+	switch m.gameState {
+	case "playing":
+		return screenPlaying
+	case "finished":
+		return screenFinished
+	case "waiting", "opponent_disconnected", "spectating", "puzzle":
+		return screenWaiting
+	case "replay":
+		return screenReplay
+	}
+	return 0
+}
+
+// boardScreen reports whether the player's key bindings apply: on screens
+// with a board, but not the lobby and its lists
+func (m model) boardScreen() bool {
+	return m.keyScreen() != 0
+}
+
+// setKeys changes key bindings from a line like "up=w left=a down=s right=d
+// draw=g watch=v quit=none", or goes back to the defaults with "reset",
+// remembering them for players with an account. It refuses bindings that
+// would leave two actions on one screen with the same key.
+func (m model) setKeys(input string) model {
+	bound := maps.Clone(m.prefs.Keys)
+	if bound == nil {
+		bound = make(map[string][]string)
+	}
+	for _, word := range strings.Fields(input) {
+		if strings.EqualFold(word, "reset") {
+			clear(bound)
+			continue
+		}
+		action, list, ok := strings.Cut(word, "=")
+		action = strings.ToLower(action)
+		if !ok || !slices.ContainsFunc(keyBindings, func(b keyBinding) bool { return string(b.action) == action }) {
+			m.notice = fmt.Sprintf("Can't bind %q; write action=keys, e.g. up=w,up, or quit=none. Press ? for the actions.", word)
+			return m
+		}
+		var keys []string
+		for _, name := range strings.Split(list, ",") {
+			if strings.EqualFold(name, "none") || name == "" {
+				continue
+			}
+			key, err := parseKey(name)
+			if err != nil {
+				m.notice = err.Error()
+				return m
+			}
+			keys = append(keys, key)
+		}
+		bound[action] = keys
+	}
+
+	// A key can only do one thing on each screen, so it can't go to two of
+	// the player's actions, or to one while another still has it by default
+	for i, a := range keyBindings {
+		for _, b := range keyBindings[i+1:] {
+			if a.screens&b.screens == 0 {
+				continue
+			}
+			if clash := keyClash(bound, a, b); clash != "" {
+				m.notice = clash
+				return m
+			}
+			if clash := keyClash(bound, b, a); clash != "" {
+				m.notice = clash
+				return m
+			}
+		}
+	}
+
+	if len(bound) == 0 {
+		bound = nil
+	}
+	m.prefs.Keys = bound
+	m.notice = "Key bindings saved; press ? on the board to see them."
+	if err := savePreferences(GetGameManager().Accounts(), m.player.Identity, m.prefs); err != nil {
+		m.notice = fmt.Sprintf("Failed to save your preferences: %v", err)
+	}
+	return m
+}
+
+// keyClash describes a key the player has bound to a that b also uses,
+// either as the player's own binding or as one of its defaults
+func keyClash(bound map[string][]string, a, b keyBinding) string {
+	keys, ok := bound[string(a.action)]
+	if !ok {
+		return ""
+	}
+	others, rebound := bound[string(b.action)]
+	if !rebound {
+		others = b.keys
+	}
+	for _, key := range keys {
+		if !slices.Contains(others, key) {
+			continue
+		}
+		if rebound {
+			return fmt.Sprintf("%s can't be bound to both %s and %s.", keyLabel(key), a.action, b.action)
+		}
+		return fmt.Sprintf("%s is %s's key; give %s another key as well, e.g. %s=%s %s=none.",
+			keyLabel(key), b.action, b.action, a.action, keyLabel(key), b.action)
+	}
+	return ""
+}
+
+// helpView lists the keys for the board screens, as the player has bound them
+func (m model) helpView() string {
+	var s strings.Builder
+	s.WriteString("CheSSH keys\n\n")
+	for _, binding := range keyBindings {
+		candidates, ok := m.prefs.Keys[string(binding.action)]
+		if !ok {
+			candidates = binding.keys
+		}
+		keys := m.keymap(binding.screens)
+		var bound []string
+		for _, key := range candidates {
+			if keys[key] == binding.action {
+				bound = append(bound, keyLabel(key))
+			}
+		}
+		label := strings.Join(bound, ", ")
+		if label == "" {
+			label = "(none)"
+		}
+		s.WriteString(fmt.Sprintf("  %-14s %-14s %s\n", binding.action, label, binding.help))
+	}
+	s.WriteString("\nThe lobby lists its own keys at the top.\n")
+	s.WriteString("Press K in the lobby to bind keys, e.g. up=w,up left=a down=s right=d draw=g watch=v, or reset.\n\n")
+	s.WriteString("Press any key to close this help.\n")
+	return s.String()
+}
+
+// keysSummary writes the player's own key bindings the way setKeys reads them
+func (m model) keysSummary() string {
+	var words []string
+	for _, binding := range keyBindings {
+		keys, ok := m.prefs.Keys[string(binding.action)]
+		if !ok {
+			continue
+		}
+		var names []string
+		for _, key := range keys {
+			names = append(names, keyLabel(key))
+		}
+		if len(names) == 0 {
+			names = []string{"none"}
+		}
+		words = append(words, fmt.Sprintf("%s=%s", binding.action, strings.Join(names, ",")))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyBindingDefaults(t *testing.T) {
+	for _, screen := range []keyScreens{screenPlaying, screenFinished, screenWaiting, screenReplay} {
+		owner := make(map[string]keyAction)
+		for _, binding := range keyBindings {
+			if binding.screens&screen == 0 {
+				continue
+			}
+			for _, key := range binding.keys {
+				if other, ok := owner[key]; ok {
+					t.Errorf("on screen %b, %q is the default key of both %s and %s", screen, key, other, binding.action)
+				}
+				owner[key] = binding.action
+			}
+		}
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		clash string // Part of the notice when the bindings are refused
+	}{
+		{"up=w,up left=a down=s right=d", "w is watch's key"},
+		{"up=w,up left=a down=s right=d watch=v", "d is draw's key"},
+		{"up=w,up left=a down=s right=d draw=g watch=v", ""},
+		{"left=a", ""},
+		{"resign=z room=z", ""},
+		{"resign=z draw=z", "can't be bound to both"},
+		{"rematch=d", "d is norematch's key"},
+		{"down=J", "J is join's key"},
+		{"down=J join=none", ""},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			m := initialModelWithPlayer(&Player{ID: "player_a", Name: "a"})
+			m = m.setKeys(tt.input)
+			if tt.clash != "" {
+				if !strings.Contains(m.notice, tt.clash) {
+					t.Errorf("notice = %q, want it to mention %q", m.notice, tt.clash)
+				}
+				if m.prefs.Keys != nil {
+					t.Errorf("saved %v despite the clash", m.prefs.Keys)
+				}
+				return
+			}
+			if m.prefs.Keys == nil {
+				t.Errorf("bindings refused: %s", m.notice)
+			}
+		})
+	}
+}
+
+func TestBoardScreenKeys(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		state      string
+		bindings   string
+		key        string
+		wantPrompt bool
+		wantRow    int
+	}{
+		{"J joins a room while waiting", "waiting", "", "J", true, 4},
+		{"j moves down while waiting", "waiting", "", "j", false, 3},
+		{"J joins a room while watching", "spectating", "", "J", true, 4},
+		{"j moves down while watching", "spectating", "", "j", false, 3},
+		{"J joins a room in a puzzle", "puzzle", "", "J", true, 4},
+		{"J joins a room after a game", "finished", "", "J", true, 4},
+		{"j joins a room after a game, where nothing moves down", "finished", "", "j", true, 4},
+		{"join rebound", "waiting", "join=g", "g", true, 4},
+		{"join's default taken away", "waiting", "join=g", "J", false, 4},
+		{"down rebound", "waiting", "down=s", "s", false, 3},
+		{"down's default taken away", "waiting", "down=s", "j", false, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := initialModelWithPlayer(&Player{ID: "player_a", Name: "a"})
+			if tt.bindings != "" {
+				if m = m.setKeys(tt.bindings); m.prefs.Keys == nil {
+					t.Fatalf("bindings refused: %s", m.notice)
+				}
+			}
+			m.gameState = tt.state
+			m.cursorRow, m.cursorCol = 4, 4
+			if tt.state == "puzzle" {
+				m = m.startPuzzle()
+				m.cursorRow, m.cursorCol = 4, 4
+			}
+
+			next, _ := m.Update(keyMsg(tt.key))
+			m = next.(model)
+			if got := m.inputPrompt == "Room code: "; got != tt.wantPrompt {
+				t.Errorf("room code prompt = %v, want %v (prompt %q)", got, tt.wantPrompt, m.inputPrompt)
+			}
+			if m.cursorRow != tt.wantRow {
+				t.Errorf("cursor row = %d, want %d", m.cursorRow, tt.wantRow)
+			}
+		})
+	}
+}
+
+func TestTranslateKeyByScreen(t *testing.T) {
+	m := initialModelWithPlayer(&Player{ID: "player_a", Name: "a"})
+	for _, tt := range []struct {
+		state, key, want string
+	}{
+		{"playing", "r", "r"},
+		{"finished", "r", "r"},
+		{"waiting", "k", "up"},
+		{"finished", "k", "k"},
+		{"replay", "h", "left"},
+		{"replay", "j", "j"},
+	} {
+		m.gameState = tt.state
+		got, ok := m.translateKey(keyMsg(tt.key))
+		if !ok || got.String() != tt.want {
+			t.Errorf("on %s, %q translates to %q (%v), want %q", tt.state, tt.key, got.String(), ok, tt.want)
+		}
+	}
+}
+
+func TestAnyKeyClosesHelp(t *testing.T) {
+	m := initialModelWithPlayer(&Player{ID: "player_a", Name: "a"})
+	m = m.setKeys("down=s")
+	m.gameState = "waiting"
+	for _, key := range []string{"s", "j", "z"} {
+		m.showHelp = true
+		next, _ := m.Update(keyMsg(key))
+		if next.(model).showHelp {
+			t.Errorf("%q didn't close the help", key)
+		}
+	}
+}
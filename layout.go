@@ -0,0 +1,75 @@
+package main
+
+import "strings"
+
+const (
+	// columnGap separates the board, the move list and the game info
+	columnGap = 2
+	// infoWidth is the width of the game info box
+	infoWidth = 23
+	// layoutHeaderLines is about how many lines the views put above the board
+	layoutHeaderLines = 8
+	// minTerminalWidth and minTerminalHeight fit the compact board with at
+	// least a line of the move list under it
+	minTerminalWidth  = moveListWidth
+	minTerminalHeight = layoutHeaderLines + 10 + 2
+)
+
+// boardSizes are the square sizes to try, largest first: squares 7 cells
+// wide and 3 lines tall look square in most fonts, then the classic 3x1,
+// then one cell per square
+var boardSizes = []struct{ width, height int }{{7, 3}, {3, 1}, {1, 1}}
+
+// boardLayout is how the board and the panels next to it fit in the terminal
+type boardLayout struct {
+	squareWidth  int  // Cells per square
+	squareHeight int  // Lines per square
+	infoBelow    bool // The move list and game info go under the board instead of beside it
+	panelRoom    int  // Lines the panels may take up, if they don't all fit
+	tooSmall     bool // Not even the compact board fits
+}
+
+// layout picks the largest board that fits the terminal along with the
+// move list and game info, beside it if there's room and under it if not
+func (m model) layout() boardLayout {
+	if m.width == 0 || m.height == 0 {
+		// The terminal didn't tell us its size
+		return boardLayout{squareWidth: 3, squareHeight: 1}
+	}
+	if m.width < minTerminalWidth || m.height < minTerminalHeight {
+		return boardLayout{tooSmall: true}
+	}
+
+	// The move list and game info can be cut short beside the board, but
+	// under it they have to fit
+	room := m.height - layoutHeaderLines
+	below := max(len(m.getMoveListLines()), len(m.getInfoLines())+len(m.getChatLines()))
+	if m.width < moveListWidth+columnGap+infoWidth {
+		below = len(m.getMoveListLines()) + len(m.getInfoLines()) + len(m.getChatLines())
+	}
+	for _, size := range boardSizes {
+		width, height := 8*size.width+2, 8*size.height+2
+		layout := boardLayout{squareWidth: size.width, squareHeight: size.height}
+		if width+columnGap+moveListWidth+columnGap+infoWidth <= m.width && height <= room {
+			layout.panelRoom = room
+			return layout
+		}
+		if width <= m.width && height+1+below <= room {
+			layout.infoBelow = true
+			return layout
+		}
+	}
+
+	// Nothing fits whole, so keep the compact board on screen and cut the
+	// panels under it short
+	return boardLayout{squareWidth: 1, squareHeight: 1, infoBelow: true, panelRoom: room - 10 - 1}
+}
+
+// truncateLines keeps the first n lines of s
+func truncateLines(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[:max(0, n)], "")
+}
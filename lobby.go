@@ -0,0 +1,447 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lobbyTickMsg refreshes the lobby listings
+type lobbyTickMsg time.Time
+
+// LobbyPlayer is an online player and what they're doing
+type LobbyPlayer struct {
+	Player *Player
+	Status string // "idle", "seeking", "playing", "watching" or "in a room"
+}
+
+// Seek is a player waiting in the queue for a game
+type Seek struct {
+	Player   *Player
+	Settings GameSettings
+	Rating   Rating
+	Joined   time.Time
+}
+
+// LiveGame is a public game in progress that anyone can watch
+type LiveGame struct {
+	ID         string
+	White      *Player
+	Black      *Player
+	Settings   GameSettings
+	Moves      int
+	Spectators int
+	Started    time.Time
+}
+
+// Lobby is a snapshot of what's happening on the server
+type Lobby struct {
+	Players []LobbyPlayer
+	Seeks   []Seek
+	Games   []LiveGame
+}
+
+// Lobby returns the online players, open seeks and public games in progress
+func (gm *GameManager) Lobby() Lobby {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	var lobby Lobby
+	for _, entry := range gm.playerQueue {
+		lobby.Seeks = append(lobby.Seeks, Seek{
+			Player:   entry.player,
+			Settings: entry.settings,
+			Rating:   entry.rating,
+			Joined:   entry.joined,
+		})
+	}
+
+	for _, session := range gm.activeGames {
+		if session.Private {
+			continue
+		}
+		if result, _ := session.GetResult(); result != nil {
+			continue
+		}
+		session.mu.RLock()
+		moves := len(session.Game.MoveHistory)
+		session.mu.RUnlock()
+		lobby.Games = append(lobby.Games, LiveGame{
+			ID:         session.ID,
+			White:      session.White,
+			Black:      session.Black,
+			Settings:   session.GameSettings,
+			Moves:      moves,
+			Spectators: session.SpectatorCount(),
+			Started:    session.Started,
+		})
+	}
+	sort.Slice(lobby.Games, func(i, j int) bool {
+		return lobby.Games[i].Started.Before(lobby.Games[j].Started)
+	})
+
+	for _, player := range gm.players {
+		status := "idle"
+		switch {
+		case gm.inGameLocked(player.ID):
+			status = "playing"
+		case gm.watching[player.ID] != "":
+			status = "watching"
+		case gm.queuedLocked(player.ID):
+			status = "seeking"
+		case gm.hasRoomLocked(player.ID):
+			status = "in a room"
+		}
+		lobby.Players = append(lobby.Players, LobbyPlayer{Player: player, Status: status})
+	}
+	sort.Slice(lobby.Players, func(i, j int) bool {
+		return lobby.Players[i].Player.Name < lobby.Players[j].Player.Name
+	})
+
+	return lobby
+}
+
+// queuedLocked reports whether the player is waiting in the matchmaking
+// queue. Callers must hold gm.mu.
+func (gm *GameManager) queuedLocked(playerID string) bool {
+	for _, entry := range gm.playerQueue {
+		if entry.player.ID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRoomLocked reports whether the player has a private room waiting for an
+// opponent. Callers must hold gm.mu.
+func (gm *GameManager) hasRoomLocked(playerID string) bool {
+	for _, room := range gm.rooms {
+		if room.Creator.ID == playerID && room.GameID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptSeek starts a game against a player waiting in the queue
+func (gm *GameManager) AcceptSeek(seekerID string, player *Player) error {
+	if seekerID == player.ID {
+		return errors.New("you can't accept your own seek")
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.inGameLocked(player.ID) {
+		return errors.New("finish your current game before starting another")
+	}
+	for _, entry := range gm.playerQueue {
+		if entry.player.ID == seekerID {
+			settings := entry.settings
+			if err := checkCorrespondence(settings, player); err != nil {
+				return err
+			}
+			settings.Rated = canBeRated(entry.player, player)
+			white, black := gm.assignColorsLocked(entry.player, player, ColorRandom)
+			gm.startGameLocked(white, black, settings)
+			return nil
+		}
+	}
+	return errors.New("that seek is no longer open")
+}
+
+// ParseSeekArgs parses quick pairing options like "5+3 threecheck". Whether
+// the game is rated is decided when the players are paired.
+func ParseSeekArgs(args []string) (GameSettings, error) {
+	settings := GameSettings{TimeControl: DefaultTimeControl}
+	for _, arg := range args {
+		arg = strings.ToLower(arg)
+		if isTimeControl(arg) {
+			tc, err := ParseTimeControl(arg)
+			if err != nil {
+				return settings, err
+			}
+			settings.TimeControl = tc
+			continue
+		}
+		variant, err := ParseVariant(arg)
+		if err != nil {
+			return settings, fmt.Errorf("unknown option %q", arg)
+		}
+		settings.Variant = variant
+	}
+	return settings, nil
+}
+
+// lobbyItem is a selectable line in the lobby
+type lobbyItem struct {
+	seek           *Seek
+	game           *LiveGame
+	player         *Player
+	correspondence *CorrespondenceGame
+	tournament     *TournamentInfo
+}
+
+func (m model) lobbyTick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return lobbyTickMsg(t)
+	})
+}
+
+// lobbyItems lists the lobby's selectable lines in display order
+func (m model) lobbyItems() []lobbyItem {
+	var items []lobbyItem
+	for i := range m.correspondence {
+		items = append(items, lobbyItem{correspondence: &m.correspondence[i]})
+	}
+	for i := range m.lobby.Seeks {
+		items = append(items, lobbyItem{seek: &m.lobby.Seeks[i]})
+	}
+	for i := range m.lobby.Games {
+		items = append(items, lobbyItem{game: &m.lobby.Games[i]})
+	}
+	for i := range m.tournaments {
+		items = append(items, lobbyItem{tournament: &m.tournaments[i]})
+	}
+	for _, p := range m.lobby.Players {
+		if p.Player.ID != m.player.ID {
+			items = append(items, lobbyItem{player: p.Player})
+		}
+	}
+	return items
+}
+
+// enterLobby leaves whatever the player was doing and shows the lobby
+func (m model) enterLobby() model {
+	m.gameState = "lobby"
+	m.gameSession = nil
+	m.opponent = nil
+	m.puzzle = nil
+	m.history, m.replay = nil, nil
+	m.profile = nil
+	m.game = NewGame()
+	m.isMyTurn = false
+	m.selected = nil
+	m.validMoves = make([]Position, 0)
+	m.premoves = nil
+	m.result, m.ratingChanges = nil, nil
+	m.moverCursor, m.moverSelected, m.moverMoves = nil, nil, nil
+	m.flipped = false
+	m.moveScroll = 0
+	m.spectatorCount = 0
+	m.chat = nil
+	m.lobby = GetGameManager().Lobby()
+	m.correspondence = GetGameManager().CorrespondenceGames(m.player.Identity)
+	m.tournaments = GetGameManager().Tournaments()
+	m.tournamentID = ""
+	return m
+}
+
+// handleLobbyKey moves the lobby selection and acts on the selected line
+func (m model) handleLobbyKey(msg tea.KeyMsg) (model, tea.Cmd) {
+	items := m.lobbyItems()
+	switch msg.String() {
+	case "up":
+		if m.lobbyCursor > 0 {
+			m.lobbyCursor--
+		}
+	case "down":
+		if m.lobbyCursor < len(items)-1 {
+			m.lobbyCursor++
+		}
+	case "enter":
+		if m.lobbyCursor >= len(items) {
+			return m, nil
+		}
+		item := items[m.lobbyCursor]
+		switch {
+		case item.correspondence != nil:
+			m = m.openCorrespondence(item.correspondence.ID)
+		case item.seek != nil:
+			if item.seek.Player.ID == m.player.ID {
+				return m, nil
+			}
+			if err := GetGameManager().AcceptSeek(item.seek.Player.ID, m.player); err != nil {
+				m.notice = err.Error()
+			}
+		case item.game != nil:
+			return m.watch(item.game.ID)
+		case item.tournament != nil:
+			m = m.openTournament(item.tournament.ID)
+		case item.player != nil:
+			name := item.player.Name
+			if item.player.Identity != "" {
+				name = item.player.Identity
+			}
+			m.inputPrompt = "Challenge (user [5+3] [white|black] [variant] [casual]): "
+			m.inputAction = "challenge"
+			m.input = name + " "
+		}
+	case "p":
+		m.inputPrompt = "Quick pairing ([5+3] [variant], Enter for 10+0 Standard): "
+		m.inputAction = "seek"
+	case "b":
+		m.inputPrompt = "Play the bot ([5+3] [variant], Enter for 10+0 Standard): "
+		m.inputAction = "bot"
+	case "t":
+		m.inputPrompt = "Tournament (swiss [rounds] | roundrobin | arena [minutes]) [5+3] [variant] [casual]: "
+		m.inputAction = "tournament"
+	case "z":
+		m = m.startPuzzle()
+	case "g":
+		m = m.showHistory()
+	case "i":
+		m.inputPrompt = "Profile of (Enter for your own): "
+		m.inputAction = "profile"
+		// Suggest the selected player
+		if m.lobbyCursor < len(items) && items[m.lobbyCursor].player != nil {
+			m.input = items[m.lobbyCursor].player.Identity
+		}
+	case "l":
+		m = m.showLeaderboard()
+	case "s":
+		m.inputPrompt = fmt.Sprintf("Board theme (%s) and pieces (%s): ", strings.Join(themeNames(), ", "), strings.Join(pieceSetNames(), ", "))
+		m.inputAction = "style"
+		m.input = m.theme().Name + " " + m.pieceSet().Name
+	case "a":
+		m.inputPrompt = fmt.Sprintf("Alerts when it's your move (%s, or none): ", strings.Join(alertNames(), ", "))
+		m.inputAction = "alerts"
+		m.input = m.alertsSummary()
+	case "k":
+		m.inputPrompt = "Key bindings (action=keys, e.g. up=w,up left=a down=s right=d draw=g watch=v; reset for the defaults): "
+		m.inputAction = "keys"
+		m.input = m.keysSummary()
+	case "o":
+		for _, game := range m.correspondence {
+			if game.YourMove {
+				return m.openCorrespondence(game.ID), nil
+			}
+		}
+		m.notice = "None of your correspondence games are waiting for you."
+	default:
+		m = m.handleChallengeKey(msg)
+	}
+	return m, nil
+}
+
+// seek joins the matchmaking queue
+func (m model) seek(args []string) model {
+	settings, err := ParseSeekArgs(args)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	if err := checkCorrespondence(settings, m.player); err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	GetGameManager().AddPlayer(m.player, settings)
+	if GetGameManager().GetGameSession(m.player.ID) == nil {
+		m.gameState = "waiting"
+		m.seekSettings = settings
+	}
+	m.notice = ""
+	return m
+}
+
+// playBot starts a game against the built-in bot
+func (m model) playBot(args []string) model {
+	settings, err := ParseSeekArgs(args)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	if settings.TimeControl.Correspondence() {
+		m.notice = "The bot only plays live games."
+		return m
+	}
+	GetGameManager().PlayBot(m.player, settings)
+	m.notice = ""
+	return m
+}
+
+// startPuzzle shows a random puzzle
+func (m model) startPuzzle() model {
+	puzzle := randomPuzzle()
+	game, err := NewGameFromFEN(puzzle.FEN)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.gameState = "puzzle"
+	m.game = game
+	m.puzzle = &puzzleState{puzzle: puzzle, color: game.CurrentTurn}
+	m.selected = nil
+	m.validMoves = make([]Position, 0)
+	m.notice = ""
+	return m
+}
+
+// lobbyView renders the lobby listings
+func (m model) lobbyView() string {
+	var s strings.Builder
+	items := m.lobbyItems()
+	line := 0
+	cursor := func() string {
+		defer func() { line++ }()
+		if line == m.lobbyCursor {
+			return "> "
+		}
+		return "  "
+	}
+
+	if n := m.yourMoveCount(); n > 0 {
+		games := "games"
+		if n == 1 {
+			games = "game"
+		}
+		s.WriteString(fmt.Sprintf("*** It's your move in %d correspondence %s; press O to play ***\n\n", n, games))
+	}
+	s.WriteString(m.correspondenceLines(cursor))
+	s.WriteString("Open seeks\n")
+	if len(m.lobby.Seeks) == 0 {
+		s.WriteString("  (none; press P to start one)\n")
+	}
+	for _, seek := range m.lobby.Seeks {
+		name := seek.Player.Name
+		if seek.Player.ID == m.player.ID {
+			name += " (you)"
+		}
+		s.WriteString(fmt.Sprintf("%s%-24s %-6s %s %s, waiting %ds\n", cursor(), name, seek.Rating,
+			seek.Settings.TimeControl, seek.Settings.Variant.Title(), int(time.Since(seek.Joined).Seconds())))
+	}
+
+	s.WriteString("\nLive games\n")
+	if len(m.lobby.Games) == 0 {
+		s.WriteString("  (none)\n")
+	}
+	for _, game := range m.lobby.Games {
+		s.WriteString(fmt.Sprintf("%s%s vs %s, %s, %d moves, %d watching\n", cursor(),
+			game.White.Name, game.Black.Name, game.Settings, game.Moves, game.Spectators))
+	}
+
+	s.WriteString("\nTournaments\n")
+	if len(m.tournaments) == 0 {
+		s.WriteString("  (none; press T to organize one)\n")
+	}
+	for _, t := range m.tournaments {
+		s.WriteString(fmt.Sprintf("%s%s, %s: %s\n", cursor(), t.Name, t.Settings, t.Status))
+	}
+
+	s.WriteString("\nOnline players\n")
+	others := len(items) - len(m.correspondence) - len(m.lobby.Seeks) - len(m.lobby.Games) - len(m.tournaments)
+	if others == 0 {
+		s.WriteString("  (nobody else yet)\n")
+	}
+	for _, p := range m.lobby.Players {
+		if p.Player.ID == m.player.ID {
+			continue
+		}
+		s.WriteString(fmt.Sprintf("%s%-24s %s\n", cursor(), p.Player.Name, p.Status))
+	}
+	return s.String()
+}
@@ -17,10 +17,12 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/bubbletea"
@@ -36,15 +38,82 @@ type model struct {
 	cursorCol  int
 	selected   *Position
 	validMoves []Position
+	premoves   []premove // Queued during the opponent's turn
 
 	// Multiplayer state
 	player      *Player
 	opponent    *Player
 	gameSession *GameSession
-	gameState   string // "waiting", "playing", "finished", "opponent_disconnected"
+	gameState   string // "lobby", "waiting", "playing", "finished", "opponent_disconnected", "spectating", "puzzle", "history", "replay", "tournament", "profile", "leaderboard"
 	isMyTurn    bool
+
+	lobby          Lobby                // Refreshed every second while in the lobby
+	lobbyCursor    int                  // Selected lobby line
+	correspondence []CorrespondenceGame // The player's correspondence games, shown in the lobby
+	seekSettings   GameSettings         // What we're waiting in the queue for
+
+	puzzle *puzzleState // Set while solving a puzzle
+
+	history       []*GameRecord // Our finished games, newest first
+	historyCursor int
+	replay        *replayState // Set while replaying a stored game
+
+	profile         *Profile // The profile being shown
+	leaderboardPool int      // Index into ratingPools of the leaderboard being shown
+
+	tournaments     []TournamentInfo // Refreshed every second in the lobby and on the standings screen
+	tournamentID    string           // The tournament whose standings are shown
+	endedTournament string           // The last tournament we played in that has finished
+
+	spectatorCount int        // How many people are watching our game
+	moverCursor    *Position  // The mover's cursor, shown to spectators and the opponent
+	moverSelected  *Position  // The piece the mover has picked up
+	moverMoves     []Position // Where the mover's selected piece can go
+
+	chat      []ChatMessage // Chat in the current game
+	chatMuted bool
+
+	confirmResign bool   // Waiting for the player to confirm resigning
+	drawOffer     string // "sent" or "received" while a draw offer is open
+	rematch       string // "sent" or "received" while a rematch offer is open
+
+	// Set once the game is over
+	result        *GameResult
+	ratingChanges *[2]RatingChange
+
+	// Challenges
+	challenges []*Challenge // Incoming, oldest first
+	outgoing   *Challenge
+
+	room *Room // Private room we're waiting in
+
+	// Single-line text input, active while inputPrompt is set
+	inputPrompt string
+	input       string
+	inputAction string // What to do with the input when Enter is pressed
+
+	notice string // One-off message shown above the board
+
+	prefs    Preferences        // Display settings, saved to the player's account
+	renderer *lipgloss.Renderer // Draws the board in as many colors as the player's terminal has
+	flipped  bool               // Draw the board from the other side
+	title    string             // The window title we last set
+	showHelp bool               // Show the keys instead of the screen
+
+	moveScroll int // How many moves the move list is scrolled back from the latest
+
+	drag          *mouseDrag // Set while the mouse button is held down over the board
+	boardTop      *int       // Screen line the board was last drawn from, or -1; a pointer since View can't change the model
+	width, height int        // Terminal size, from the latest tea.WindowSizeMsg
+
+	accessible bool     // Plain text for screen readers instead of the board, see accessible.go
+	spoken     []string // What the accessible mode has said, oldest first
+	heard      int      // How many of the game's moves the accessible mode has read out
 }
 
+// clockTickMsg redraws the clocks while a timed game is running
+type clockTickMsg time.Time
+
 func initialModel() model {
 	return model{
 		game:       NewGame(),
@@ -52,25 +121,36 @@ func initialModel() model {
 		cursorCol:  0,
 		selected:   nil,
 		validMoves: make([]Position, 0),
-		gameState:  "waiting",
+		gameState:  "lobby",
 		isMyTurn:   false,
+		boardTop:   new(int),
 	}
 }
 
 func initialModelWithPlayer(player *Player) model {
 	m := initialModel()
 	m.player = player
-	m.gameState = "waiting"
+	m.gameState = "lobby"
+	m.prefs = loadPreferences(GetGameManager().Accounts(), player.Identity)
 	return m
 }
 
 func (m model) Init() tea.Cmd {
 	if m.player != nil && m.player.UpdateChan != nil {
-		return m.listenForUpdates()
+		return tea.Batch(m.listenForUpdates(), m.clockTick(), m.lobbyTick())
 	}
 	return nil
 }
 
+func (m model) clockTick() tea.Cmd {
+	if m.gameSession == nil || m.gameSession.Clock == nil || m.result != nil {
+		return nil
+	}
+	return tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg {
+		return clockTickMsg(t)
+	})
+}
+
 func (m model) listenForUpdates() tea.Cmd {
 	return func() tea.Msg {
 		if m.player != nil && m.player.UpdateChan != nil {
@@ -80,14 +160,52 @@ func (m model) listenForUpdates() tea.Cmd {
 	}
 }
 
+// Update handles a message, with the player's key bindings, then keeps the
+// window title in step with the game
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && m.inputPrompt == "" && !m.showHelp && !m.accessible && m.boardScreen() {
+		// Keys the player has bound stand in for the default keys of their
+		// actions, which are all the key handling knows. Under the help any
+		// key closes it, bound or not.
+		var bound bool
+		if msg, bound = m.translateKey(key); !bound {
+			return m, nil
+		}
+	}
+
+	next, cmd := m.update(msg)
+	m = next.(model)
+	if title := m.windowTitle(); title != m.title {
+		m.title = title
+		cmd = tea.Batch(cmd, m.setWindowTitle(title))
+	}
+	return m, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.accessible {
+			return m.handleAccessibleKey(msg)
+		}
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if m.inputPrompt != "" {
+			return m.handleInputKey(msg)
+		}
+		if m.showHelp {
+			// Any key closes the help
+			m.showHelp = false
+			return m, nil
+		}
+
 		// Global keys
 		switch msg.Type {
-		case tea.KeyCtrlC:
-			return m, tea.Quit
 		case tea.KeyEscape:
+			if m.gameState == "playing" && !m.isMyTurn {
+				m = m.cancelPremoves()
+			}
 			if m.gameState == "playing" && m.isMyTurn {
 				m.selected = nil
 				m.validMoves = make([]Position, 0)
@@ -104,29 +222,71 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "q":
 			return m, tea.Quit
+		case "?":
+			m.showHelp = true
+			return m, nil
+		case "pgup":
+			return m.scrollMoveList(moveListRows), nil
+		case "pgdown":
+			return m.scrollMoveList(-moveListRows), nil
+		}
+
+		switch m.gameState {
+		case "lobby":
+			return m.handleLobbyKey(msg)
+		case "puzzle":
+			return m.handlePuzzleKey(msg), nil
+		case "history":
+			return m.handleHistoryKey(msg), nil
+		case "replay":
+			return m.handleReplayKey(msg), nil
+		case "tournament":
+			return m.handleTournamentKey(msg), nil
+		case "profile":
+			return m.handleProfileKey(msg), nil
+		case "leaderboard":
+			return m.handleLeaderboardKey(msg), nil
+		}
+
+		if m.gameSession != nil {
+			switch msg.String() {
+			case "t":
+				m.inputPrompt = "Say: "
+				m.inputAction = "chat"
+				return m, nil
+			case "m":
+				m.chatMuted = !m.chatMuted
+				return m, nil
+			case "o":
+				return m.toggleOpponentCursor(), nil
+			case "f":
+				m.flipped = !m.flipped
+				return m, nil
+			}
+		}
+
+		if m.gameState == "playing" {
+			if handled, next := m.handleGameKey(msg); handled {
+				return next, nil
+			}
+		}
+
+		if m.gameState == "finished" {
+			if handled, next := m.handleRematchKey(msg); handled {
+				return next, nil
+			}
+		}
+
+		if m.gameState != "playing" {
+			m = m.handleChallengeKey(msg)
 		}
 
 		// Only handle game input if it's the player's turn and game is active
 		if m.gameState == "playing" && m.isMyTurn {
 			switch msg.String() {
-			case "up", "k":
-				if m.cursorRow < 7 {
-					m.cursorRow++
-					m.broadcastCursorUpdate()
-				}
-			case "down", "j":
-				if m.cursorRow > 0 {
-					m.cursorRow--
-					m.broadcastCursorUpdate()
-				}
-			case "left", "h":
-				if m.cursorCol > 0 {
-					m.cursorCol--
-					m.broadcastCursorUpdate()
-				}
-			case "right", "l":
-				if m.cursorCol < 7 {
-					m.cursorCol++
+			case "up", "k", "down", "j", "left", "h", "right", "l":
+				var moved bool
+				if m, moved = m.moveCursor(msg.String()); moved {
 					m.broadcastCursorUpdate()
 				}
 			case "enter", " ":
@@ -155,50 +315,82 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							Type: "deselect",
 							Data: nil,
 						})
-					} else if m.game.MakeMove(*m.selected, currentPos) {
-						// Move successful - broadcast to opponent
-						GetGameManager().BroadcastUpdate(m.player.ID, GameUpdate{
-							Type: "move",
-							Data: map[string]interface{}{
-								"from":      *m.selected,
-								"to":        currentPos,
-								"gameState": m.game,
-							},
-						})
+					} else if m.gameSession.MakeMove(m.player.ID, *m.selected, currentPos) {
+						// Move successful - the session broadcasts it to the opponent
 						m.selected = nil
 						m.validMoves = make([]Position, 0)
 						m.isMyTurn = false
 					}
 				}
 			}
-		} else if m.gameState == "waiting" || m.gameState == "opponent_disconnected" {
-			// In waiting mode or after opponent disconnect, allow basic navigation for UI exploration but no moves
+		} else if m.gameState == "playing" && m.result == nil {
+			// During the opponent's turn, space queues premoves instead
 			switch msg.String() {
-			case "up", "k":
-				if m.cursorRow < 7 {
-					m.cursorRow++
-				}
-			case "down", "j":
-				if m.cursorRow > 0 {
-					m.cursorRow--
-				}
-			case "left", "h":
-				if m.cursorCol > 0 {
-					m.cursorCol--
-				}
-			case "right", "l":
-				if m.cursorCol < 7 {
-					m.cursorCol++
-				}
+			case "enter", " ":
+				m = m.selectPremove(Position{m.cursorRow, m.cursorCol})
+			default:
+				m, _ = m.moveCursor(msg.String())
 			}
+		} else if m.gameState == "waiting" || m.gameState == "opponent_disconnected" || m.gameState == "spectating" {
+			// In waiting mode or after opponent disconnect, allow basic navigation for UI exploration but no moves
+			m, _ = m.moveCursor(msg.String())
 		}
 
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
 	case GameUpdate:
 		return m.handleGameUpdate(msg)
+
+	case clockTickMsg:
+		return m, m.clockTick()
+
+	case lobbyTickMsg:
+		if m.gameState == "lobby" {
+			m.lobby = GetGameManager().Lobby()
+			m.correspondence = GetGameManager().CorrespondenceGames(m.player.Identity)
+			m.tournaments = GetGameManager().Tournaments()
+			m.lobbyCursor = max(0, min(m.lobbyCursor, len(m.lobbyItems())-1))
+		}
+		if m.gameState == "tournament" {
+			m.tournaments = GetGameManager().Tournaments()
+		}
+		return m, m.lobbyTick()
 	}
 	return m, nil
 }
 
+// moveCursor moves the cursor one square in the direction of an arrow or
+// vi key as the board is drawn, reporting whether it moved
+func (m model) moveCursor(key string) (model, bool) {
+	var dRow, dCol int
+	switch key {
+	case "up", "k":
+		dRow = 1
+	case "down", "j":
+		dRow = -1
+	case "left", "h":
+		dCol = -1
+	case "right", "l":
+		dCol = 1
+	default:
+		return m, false
+	}
+	if m.perspective() == Black {
+		dRow, dCol = -dRow, -dCol
+	}
+
+	row, col := m.cursorRow+dRow, m.cursorCol+dCol
+	if row < 0 || row > 7 || col < 0 || col > 7 {
+		return m, false
+	}
+	m.cursorRow, m.cursorCol = row, col
+	return m, true
+}
+
 func (m model) broadcastCursorUpdate() {
 	if m.gameSession != nil {
 		GetGameManager().BroadcastUpdate(m.player.ID, GameUpdate{
@@ -217,41 +409,478 @@ func (m model) handleGameUpdate(update GameUpdate) (tea.Model, tea.Cmd) {
 		return m, m.listenForUpdates()
 	}
 
+	before := m
+	var cmds []tea.Cmd
+
 	switch update.Type {
 	case "matched":
 		m.gameState = "playing"
+		m.result = nil
+		m.ratingChanges = nil
+		m.challenges = nil
+		m.outgoing = nil
+		m.room = nil
+		m.puzzle = nil
+		m.notice = ""
+		m.moverCursor, m.moverSelected, m.moverMoves = nil, nil, nil
+		m.moveScroll = 0
+		m.spectatorCount = 0
+		m.chat = nil
+		m.confirmResign = false
+		m.drawOffer = ""
+		m.rematch = ""
+		m.premoves = nil
 		m.gameSession = GetGameManager().GetGameSession(m.player.ID)
 		if m.gameSession != nil {
 			m.game = m.gameSession.Game
 			m.opponent = m.gameSession.GetOpponent(m.player.ID)
-			m.isMyTurn = (m.player.Color == White) // White goes first
+			m.isMyTurn = (m.player.Color == m.game.CurrentTurn) // White goes first unless the room set a position
+			cmds = append(cmds, m.clockTick())
+		}
+
+	case "game_over":
+		if m.gameSession != nil {
+			m.result, m.ratingChanges = m.gameSession.GetResult()
+		}
+		if m.gameState == "playing" {
+			m.gameState = "finished"
+		}
+		m.isMyTurn = false
+		m.selected = nil
+		m.validMoves = make([]Position, 0)
+		m.premoves = nil
+		m.confirmResign = false
+		m.drawOffer = ""
+
+	case "draw_offered":
+		if data, ok := update.Data.(map[string]interface{}); ok {
+			color, _ := data["color"].(Color)
+			switch {
+			case m.gameState == "spectating":
+				m.notice = fmt.Sprintf("%s offers a draw.", color)
+			case color == m.player.Color:
+				m.drawOffer = "sent"
+			default:
+				m.drawOffer = "received"
+			}
+		}
+
+	case "rematch_offered":
+		if data, ok := update.Data.(map[string]interface{}); ok && m.gameState == "finished" {
+			if color, _ := data["color"].(Color); color != m.player.Color {
+				m.rematch = "received"
+			}
+		}
+
+	case "rematch_declined":
+		if m.rematch == "sent" {
+			m.notice = "Your opponent declined the rematch."
+		}
+		m.rematch = ""
+
+	case "draw_declined":
+		if m.drawOffer == "sent" {
+			m.notice = "Your draw offer was declined."
 		}
+		m.drawOffer = ""
 
 	case "move":
 		if data, ok := update.Data.(map[string]interface{}); ok {
 			// Update game state from opponent's move
 			if gameState, ok := data["gameState"].(*Game); ok {
 				m.game = gameState
-				m.isMyTurn = true // It's now our turn
+				// Spectators see both players' moves, so check whose turn it is
+				m.isMyTurn = m.gameState == "playing" && m.game.CurrentTurn == m.player.Color
+			}
+		}
+		m.moverCursor, m.moverSelected, m.moverMoves = nil, nil, nil
+		m.drawOffer = ""
+		m = m.playPremove()
+		if m.gameState == "playing" && m.isMyTurn {
+			cmds = append(cmds, m.notifyMove())
+		}
+
+	case "chat":
+		if message, ok := update.Data.(ChatMessage); ok {
+			m.chat = append(m.chat, message)
+			if len(m.chat) > chatHistoryLimit {
+				m.chat = m.chat[len(m.chat)-chatHistoryLimit:]
 			}
 		}
 
+	case "spectators":
+		if count, ok := update.Data.(int); ok {
+			m.spectatorCount = count
+		}
+
 	case "cursor":
-		// Opponent cursor movement - we could show this in UI later
+		if data, ok := update.Data.(map[string]interface{}); ok {
+			row, _ := data["row"].(int)
+			col, _ := data["col"].(int)
+			m.moverCursor = &Position{row, col}
+		}
 
 	case "select":
-		// Opponent piece selection - we could show this in UI later
+		if data, ok := update.Data.(map[string]interface{}); ok {
+			if pos, ok := data["position"].(Position); ok {
+				m.moverSelected = &pos
+			}
+			m.moverMoves, _ = data["validMoves"].([]Position)
+		}
 
 	case "deselect":
-		// Opponent deselected - clear any opponent indicators
+		m.moverSelected, m.moverMoves = nil, nil
+
+	case "challenge":
+		if challenge, ok := update.Data.(*Challenge); ok {
+			m.challenges = append(m.challenges, challenge)
+		}
+
+	case "challenge_declined", "challenge_expired", "challenge_cancelled":
+		if challenge, ok := update.Data.(*Challenge); ok {
+			m = m.dropChallenge(challenge, update.Type)
+		}
+
+	case "tournament_over":
+		if data, ok := update.Data.(map[string]interface{}); ok {
+			name, _ := data["name"].(string)
+			winner, _ := data["winner"].(string)
+			m.endedTournament = name
+			m.notice = fmt.Sprintf("%s is over; %s won. Open it from the lobby for the final standings.", name, winner)
+		}
 
 	case "opponent_disconnected":
-		m.gameState = "opponent_disconnected"
+		if m.gameSession != nil {
+			// The seat now holds a copy of the opponent as they were in this
+			// game, even if they've gone on to play another
+			if opponent := m.gameSession.GetOpponent(m.player.ID); opponent != nil {
+				m.opponent = opponent
+			}
+		}
+		if m.gameState == "playing" {
+			m.gameState = "opponent_disconnected"
+		}
+		// Starting the rematch leaves this game too, which isn't the opponent leaving
+		if m.gameState == "finished" && m.rematch != "" && GetGameManager().GetGameSession(m.player.ID) == m.gameSession {
+			m.notice = "Your opponent has left, so there won't be a rematch."
+			m.rematch = ""
+		}
 		m.isMyTurn = false // Disable input
 	}
 
+	m = m.narrate(before)
+
 	// Continue listening for updates
-	return m, m.listenForUpdates()
+	cmds = append(cmds, m.listenForUpdates())
+	return m, tea.Batch(cmds...)
+}
+
+// handleInputKey edits the text input line, running its action on Enter
+func (m model) handleInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEscape:
+		m.inputPrompt, m.input, m.inputAction = "", "", ""
+	case tea.KeyBackspace:
+		if r := []rune(m.input); len(r) > 0 {
+			m.input = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.input += " "
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	case tea.KeyEnter:
+		action, input := m.inputAction, strings.TrimSpace(m.input)
+		m.inputPrompt, m.input, m.inputAction = "", "", ""
+		return m.submitInput(action, input)
+	}
+	return m, nil
+}
+
+// submitInput runs the action the text input was opened for
+func (m model) submitInput(action, input string) (tea.Model, tea.Cmd) {
+	switch action {
+	case "challenge":
+		m = m.sendChallenge(strings.Fields(input))
+	case "room":
+		m = m.createRoom(strings.Fields(input))
+	case "join":
+		m = m.joinRoom(input)
+	case "watch":
+		return m.watch(input)
+	case "seek":
+		m = m.seek(strings.Fields(input))
+	case "bot":
+		m = m.playBot(strings.Fields(input))
+	case "tournament":
+		m = m.createTournament(strings.Fields(input))
+	case "profile":
+		m = m.showProfile(strings.TrimSpace(input))
+	case "style":
+		m = m.setStyle(input)
+	case "alerts":
+		m = m.setAlerts(input)
+	case "keys":
+		m = m.setKeys(input)
+	case "move":
+		m = m.typedMove(input)
+	case "chat":
+		if m.gameSession != nil {
+			if err := m.gameSession.Say(m.player, input); err != nil {
+				m.notice = err.Error()
+			} else {
+				m.notice = ""
+			}
+		}
+	}
+	return m, nil
+}
+
+// watch starts spectating the game of a player or private room
+func (m model) watch(target string) (model, tea.Cmd) {
+	session, err := GetGameManager().Watch(target, m.player)
+	if err != nil {
+		m.notice = err.Error()
+		return m, nil
+	}
+	m.gameState = "spectating"
+	m.gameSession = session
+	m.game = session.Game
+	m.isMyTurn = false
+	m.selected = nil
+	m.validMoves = make([]Position, 0)
+	m.result, m.ratingChanges = session.GetResult()
+	m.moverCursor, m.moverSelected, m.moverMoves = nil, nil, nil
+	m.moveScroll = 0
+	m.chat = session.ChatHistory(true)
+	m.notice = ""
+	return m, m.clockTick()
+}
+
+// stopWatching leaves the game being watched and goes back to the lobby
+func (m model) stopWatching() model {
+	GetGameManager().StopWatching(m.player.ID)
+	return m.enterLobby()
+}
+
+// createRoom opens a private room using room command syntax
+func (m model) createRoom(args []string) model {
+	settings, color, err := ParseRoomArgs(args)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	room, err := GetGameManager().CreateRoom(m.player, settings, color)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.room = room
+	m.notice = ""
+	if m.gameState == "waiting" {
+		// Opening a room takes the player out of the queue
+		m.gameState = "lobby"
+	}
+	return m
+}
+
+// joinRoom joins a private room by its code
+func (m model) joinRoom(code string) model {
+	if err := GetGameManager().JoinRoom(code, m.player); err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.notice = ""
+	return m
+}
+
+// sendChallenge challenges another player using challenge command syntax
+func (m model) sendChallenge(args []string) model {
+	target, settings, color, err := ParseChallengeArgs(args)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	challenge, err := GetGameManager().CreateChallenge(m.player, target, settings, color)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.outgoing = challenge
+	m.notice = ""
+	return m
+}
+
+// handleGameKey handles resigning and draw offers during a game. It reports
+// whether the key was used.
+func (m model) handleGameKey(msg tea.KeyMsg) (bool, model) {
+	key := msg.String()
+	if m.confirmResign {
+		m.confirmResign = false
+		if key == "y" {
+			if err := m.gameSession.Resign(m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+		}
+		return true, m
+	}
+
+	switch key {
+	case "r":
+		m.confirmResign = true
+	case "/":
+		m.inputPrompt = "Move (e.g. Nf3 or g1f3): "
+		m.inputAction = "move"
+	case "d":
+		if err := m.gameSession.OfferDraw(m.player.ID); err != nil {
+			m.notice = err.Error()
+		} else {
+			m.notice = ""
+		}
+	case "y":
+		if m.drawOffer != "received" {
+			return false, m
+		}
+		if err := m.gameSession.AcceptDraw(m.player.ID); err != nil {
+			m.notice = err.Error()
+		}
+		m.drawOffer = ""
+	case "n":
+		if m.drawOffer != "received" {
+			return false, m
+		}
+		m.gameSession.DeclineDraw(m.player.ID)
+		m.drawOffer = ""
+	case "x":
+		if !m.gameSession.TimeControl.Correspondence() {
+			return false, m
+		}
+		// The game waits in the lobby until the player comes back to it
+		GetGameManager().LeaveGame(m.player.ID)
+		return true, m.enterLobby()
+	default:
+		return false, m
+	}
+	return true, m
+}
+
+// handleChallengeKey handles challenge keys outside of a running game
+func (m model) handleChallengeKey(msg tea.KeyMsg) model {
+	switch msg.String() {
+	case "c":
+		m.inputPrompt = "Challenge (user [5+3] [white|black] [variant] [casual]): "
+		m.inputAction = "challenge"
+	case "y":
+		if len(m.challenges) > 0 {
+			challenge := m.challenges[0]
+			m.challenges = m.challenges[1:]
+			if err := GetGameManager().AcceptChallenge(challenge.ID, m.player.ID); err != nil {
+				m.notice = err.Error()
+			}
+		}
+	case "n":
+		if len(m.challenges) > 0 {
+			GetGameManager().DeclineChallenge(m.challenges[0].ID, m.player.ID)
+			m.challenges = m.challenges[1:]
+		}
+	case "r":
+		m.inputPrompt = "Room options ([white|black|random] [5+3] [variant] [rated] [fen <FEN>]): "
+		m.inputAction = "room"
+	case "j", "J":
+		// J on the board screens, where j moves the cursor down
+		m.inputPrompt = "Room code: "
+		m.inputAction = "join"
+	case "w":
+		m.inputPrompt = "Watch (player name or room code): "
+		m.inputAction = "watch"
+	case "x":
+		switch m.gameState {
+		case "spectating":
+			return m.stopWatching()
+		case "waiting":
+			GetGameManager().RemoveFromQueue(m.player.ID)
+			return m.enterLobby()
+		case "finished", "opponent_disconnected":
+			GetGameManager().LeaveGame(m.player.ID)
+			return m.enterLobby()
+		case "puzzle":
+			return m.enterLobby()
+		}
+		if m.outgoing != nil {
+			GetGameManager().CancelChallenge(m.outgoing.ID, m.player.ID)
+			m.outgoing = nil
+		}
+		if m.room != nil {
+			GetGameManager().CloseRoom(m.player.ID)
+			m.room = nil
+		}
+	}
+	return m
+}
+
+// dropChallenge forgets a challenge that is no longer open
+func (m model) dropChallenge(challenge *Challenge, reason string) model {
+	if m.outgoing != nil && m.outgoing.ID == challenge.ID {
+		m.outgoing = nil
+		switch reason {
+		case "challenge_declined":
+			m.notice = fmt.Sprintf("%s declined your challenge.", challenge.To.Name)
+		case "challenge_expired":
+			m.notice = fmt.Sprintf("%s didn't answer your challenge.", challenge.To.Name)
+		default:
+			m.notice = fmt.Sprintf("Your challenge to %s was cancelled.", challenge.To.Name)
+		}
+	}
+	for i, c := range m.challenges {
+		if c.ID == challenge.ID {
+			m.challenges = append(m.challenges[:i:i], m.challenges[i+1:]...)
+			break
+		}
+	}
+	return m
+}
+
+// challengeLines shows open challenges and the text input, if any
+func (m model) challengeLines() string {
+	var s strings.Builder
+
+	if m.notice != "" {
+		s.WriteString(m.notice + "\n")
+	}
+	if m.room != nil {
+		s.WriteString(fmt.Sprintf("Private room %s (%s). Share: ssh -t chessh.imjasonh.dev join %s\n",
+			m.room.Code, m.room.Settings, m.room.Code))
+		s.WriteString("X to close the room\n")
+	}
+	if m.outgoing != nil {
+		s.WriteString(fmt.Sprintf("Challenged %s to %s. Expires in %ds, X to cancel\n",
+			m.outgoing.To.Name, m.outgoing.Settings, int(time.Until(m.outgoing.Expires).Seconds())))
+	}
+	if len(m.challenges) > 0 {
+		c := m.challenges[0]
+		s.WriteString(fmt.Sprintf(">>> %s challenges you: %s. You play %s. Y to accept, N to decline <<<\n",
+			c.From.Name, c.Settings, challengedColor(c)))
+		if len(m.challenges) > 1 {
+			s.WriteString(fmt.Sprintf("(%d more challenges waiting)\n", len(m.challenges)-1))
+		}
+	}
+	if m.inputPrompt != "" {
+		s.WriteString(m.inputPrompt + m.input + "█\n")
+	}
+	if s.Len() > 0 {
+		s.WriteString("\n")
+	}
+	return s.String()
+}
+
+// challengedColor is the color the challenged player gets
+func challengedColor(c *Challenge) string {
+	switch c.Color {
+	case ColorWhite:
+		return "Black"
+	case ColorBlack:
+		return "White"
+	}
+	return "a random color"
 }
 
 func (m model) getValidMoves(from Position) []Position {
@@ -270,11 +899,63 @@ func (m model) getValidMoves(from Position) []Position {
 }
 
 func (m model) View() string {
+	if m.boardTop != nil {
+		*m.boardTop = -1
+	}
+	if m.accessible {
+		return m.accessibleView()
+	}
+	if m.showHelp {
+		return m.helpView()
+	}
+
 	var s strings.Builder
 
+	if m.gameState == "lobby" {
+		s.WriteString("CheSSH lobby\n")
+		s.WriteString(fmt.Sprintf("Signed in as %s\n", m.player.Name))
+		s.WriteString("P quick pairing, B play the bot, Z puzzle, T new tournament, W watch, C challenge, R/J open/join a private room\n")
+		s.WriteString("G my games, I profiles, L leaderboards, S board style, A move alerts, K keys, ? help, Q quit\n")
+		s.WriteString("Up/down to select, Enter to accept a seek, watch a game, open a tournament or challenge a player\n\n")
+		s.WriteString(m.challengeLines())
+		s.WriteString(m.lobbyView())
+		return s.String()
+	}
+
+	if m.gameState == "history" {
+		return m.historyView()
+	}
+
+	if m.gameState == "tournament" {
+		return m.tournamentView()
+	}
+
+	if m.gameState == "profile" {
+		return m.profileView()
+	}
+
+	if m.gameState == "leaderboard" {
+		return m.leaderboardView()
+	}
+
+	if m.gameState == "replay" {
+		s.WriteString("CheSSH\n")
+		s.WriteString(m.replayView())
+		m.writeBoard(&s)
+		return s.String()
+	}
+
+	if m.gameState == "puzzle" {
+		s.WriteString("CheSSH\n")
+		s.WriteString(m.puzzleView())
+		s.WriteString(m.challengeLines())
+		m.writeBoard(&s)
+		return s.String()
+	}
+
 	if m.gameState == "waiting" {
 		s.WriteString("CheSSH\n")
-		s.WriteString("Waiting for an opponent to connect...\n\n")
+		s.WriteString(fmt.Sprintf("Looking for a %s %s game...\n\n", m.seekSettings.TimeControl, m.seekSettings.Variant.Title()))
 
 		// Show queue position if available
 		if m.player != nil {
@@ -285,8 +966,27 @@ func (m model) View() string {
 		}
 
 		s.WriteString("You can explore the board while waiting:\n")
-		s.WriteString("Use arrow keys to move cursor, Q to quit\n\n")
-		s.WriteString(m.renderBoardWithInfo())
+		s.WriteString("Use arrow keys to move cursor, X to cancel and return to the lobby, Q to quit\n\n")
+		s.WriteString(m.challengeLines())
+		m.writeBoard(&s)
+		return s.String()
+	}
+
+	if m.gameState == "spectating" {
+		s.WriteString("CheSSH\n")
+		white, black := m.gameSession.White, m.gameSession.Black
+		s.WriteString(fmt.Sprintf("Watching %s (White) vs %s (Black), %s\n",
+			m.playerLabel(white), m.playerLabel(black), m.gameSession.GameSettings))
+		if m.result != nil {
+			s.WriteString(fmt.Sprintf("*** GAME OVER: %s ***\n", m.result))
+		} else if status := m.game.GameStatus(); status != "" {
+			s.WriteString(fmt.Sprintf("*** %s ***\n", status))
+		} else {
+			s.WriteString(fmt.Sprintf("%s to move\n", m.game.CurrentTurn))
+		}
+		s.WriteString("X to stop watching and return to the lobby, F to flip the board, O to show/hide the players' cursors, Q to quit\n\n")
+		s.WriteString(m.challengeLines())
+		m.writeBoard(&s)
 		return s.String()
 	}
 
@@ -294,8 +994,10 @@ func (m model) View() string {
 		s.WriteString("CheSSH\n")
 		s.WriteString("*** OPPONENT DISCONNECTED; YOU WIN ***\n\n")
 		s.WriteString("Your opponent has left the game.\n")
-		s.WriteString("You can continue exploring the board or press Q to quit.\n\n")
-		s.WriteString(m.renderBoardWithInfo())
+		s.WriteString(m.ratingSummary())
+		s.WriteString("You can continue exploring the board, press X to return to the lobby, C to challenge a player, or Q to quit.\n\n")
+		s.WriteString(m.challengeLines())
+		m.writeBoard(&s)
 		return s.String()
 	}
 
@@ -303,14 +1005,42 @@ func (m model) View() string {
 
 	if m.player != nil && m.opponent != nil {
 		s.WriteString(fmt.Sprintf("You: %s (%s) vs %s (%s)\n",
-			m.player.Name, m.player.Color, m.opponent.Name, m.opponent.Color))
+			m.playerLabel(m.player), m.player.Color, m.playerLabel(m.opponent), m.opponent.Color))
+		s.WriteString(m.seriesLine())
+		s.WriteString(m.tournamentLine())
+	}
+
+	if m.gameState == "finished" && m.result != nil {
+		s.WriteString(fmt.Sprintf("*** GAME OVER: %s ***\n", m.result))
+		s.WriteString(m.ratingSummary())
+		s.WriteString(m.rematchLines())
+		s.WriteString("Press X to return to the lobby, C to challenge a player, or Q to quit.\n\n")
+		s.WriteString(m.challengeLines())
+		m.writeBoard(&s)
+		return s.String()
 	}
 
 	if m.isMyTurn {
 		s.WriteString("YOUR TURN - Use arrow keys to move cursor\n")
-		s.WriteString("SPACE to select, ESC to deselect, Q to quit\n\n")
+		s.WriteString("SPACE to select, ESC to deselect, / to type a move, R to resign, D to offer a draw, ? for all keys, Q to quit\n\n")
 	} else {
-		s.WriteString("OPPONENT'S TURN - Please wait\n\n\n")
+		s.WriteString("OPPONENT'S TURN - SPACE or / queues premoves, ESC cancels them\n")
+		s.WriteString("R to resign, D to offer a draw, ? for all keys, Q to quit\n\n")
+	}
+	if m.gameSession != nil && m.gameSession.TimeControl.Correspondence() {
+		s.WriteString(fmt.Sprintf("Correspondence game, %d days per move. Press X to go back to the lobby; the game will wait for you.\n\n", m.gameSession.TimeControl.Days))
+	}
+
+	switch {
+	case m.confirmResign:
+		s.WriteString(">>> Resign this game? Y to confirm, any other key to cancel <<<\n\n")
+	case m.drawOffer == "received":
+		s.WriteString(">>> Your opponent offers a draw. Y to accept, N to decline <<<\n\n")
+	case m.drawOffer == "sent":
+		s.WriteString("Draw offered; waiting for your opponent to answer.\n\n")
+	}
+	if m.notice != "" {
+		s.WriteString(m.notice + "\n\n")
 	}
 
 	status := m.game.GameStatus()
@@ -318,80 +1048,225 @@ func (m model) View() string {
 		s.WriteString(fmt.Sprintf("*** %s ***\n\n", status))
 	}
 
-	s.WriteString(m.renderBoardWithInfo())
+	m.writeBoard(&s)
 
 	return s.String()
 }
 
-func (m model) renderBoardWithInfo() string {
-	boardLines := m.getBoardLines()
-	infoLines := m.getInfoLines()
+// playerLabel returns a player's name with their rating when they have an account
+func (m model) playerLabel(p *Player) string {
+	if m.gameSession == nil || p.Identity == "" {
+		return p.Name
+	}
+	return fmt.Sprintf("%s %s", p.Name, m.gameSession.Ratings[p.Color])
+}
 
-	var s strings.Builder
-	maxLines := len(boardLines)
-	if len(infoLines) > maxLines {
-		maxLines = len(infoLines)
+// ratingSummary describes both players' rating changes after a rated game
+func (m model) ratingSummary() string {
+	if m.ratingChanges == nil || m.player == nil || m.opponent == nil {
+		if m.result != nil && m.gameSession != nil && !m.gameSession.Rated {
+			return "Casual game; ratings are unchanged.\n"
+		}
+		return ""
 	}
+	return fmt.Sprintf("Rating: %s\n%s: %s\n",
+		m.ratingChanges[m.player.Color], m.opponent.Name, m.ratingChanges[m.opponent.Color])
+}
 
-	for i := 0; i < maxLines; i++ {
-		if i < len(boardLines) {
-			s.WriteString(boardLines[i])
-		} else {
-			s.WriteString(strings.Repeat(" ", 26)) // Board width padding (8*3 + 2 for row numbers)
+// toggleOpponentCursor shows or hides the other side's cursor and selection,
+// remembering the choice for players with an account
+func (m model) toggleOpponentCursor() model {
+	m.prefs.HideOpponentCursor = !m.prefs.HideOpponentCursor
+	m.notice = "Showing the opponent's cursor."
+	if m.prefs.HideOpponentCursor {
+		m.notice = "Hiding the opponent's cursor."
+	}
+	if err := savePreferences(GetGameManager().Accounts(), m.player.Identity, m.prefs); err != nil {
+		m.notice = fmt.Sprintf("Failed to save your preferences: %v", err)
+	}
+	return m
+}
+
+// writeBoard ends a view with the board, noting which screen line it
+// starts on so mouse clicks can be mapped to squares
+func (m model) writeBoard(s *strings.Builder) {
+	board := m.renderBoardWithInfo()
+	if m.boardTop != nil {
+		top := strings.Count(s.String(), "\n")
+		if m.height > 0 {
+			// The renderer drops lines that don't fit from the top
+			lines := top + strings.Count(board, "\n")
+			top -= max(0, lines+1-m.height)
 		}
+		*m.boardTop = top
+	}
+	s.WriteString(board)
+}
 
-		s.WriteString("   ")
+func (m model) renderBoardWithInfo() string {
+	layout := m.layout()
+	if layout.tooSmall {
+		// Short lines, so the notice itself fits
+		return fmt.Sprintf("TOO SMALL\nNeed %dx%d\nNow %dx%d\n",
+			minTerminalWidth, minTerminalHeight, m.width, m.height)
+	}
 
-		if i < len(infoLines) {
-			s.WriteString(infoLines[i])
+	moves, info := m.getMoveListLines(), append(m.getInfoLines(), m.getChatLines()...)
+	if !layout.infoBelow {
+		if layout.panelRoom > 0 {
+			moves, info = moves[:min(len(moves), layout.panelRoom)], info[:min(len(info), layout.panelRoom)]
 		}
+		return joinColumns(m.getBoardLines(), moves, info)
+	}
+
+	panels := joinColumns(moves) + joinColumns(info)
+	if m.width >= moveListWidth+columnGap+infoWidth {
+		panels = joinColumns(moves, info)
+	}
+	if layout.panelRoom > 0 {
+		panels = truncateLines(panels, layout.panelRoom)
+	}
+	return joinColumns(m.getBoardLines()) + "\n" + panels
+}
 
+// joinColumns lays out columns of lines side by side
+func joinColumns(columns ...[]string) string {
+	var s strings.Builder
+	maxLines := 0
+	for _, column := range columns {
+		maxLines = max(maxLines, len(column))
+	}
+
+	for i := 0; i < maxLines; i++ {
+		for j, column := range columns {
+			if j > 0 {
+				s.WriteString(strings.Repeat(" ", columnGap))
+			}
+			if i < len(column) {
+				s.WriteString(column[i])
+			} else if j < len(columns)-1 {
+				s.WriteString(strings.Repeat(" ", utf8.RuneCountInString(column[0]))) // Pad to the column's width, as wide as its first line
+			}
+		}
 		s.WriteString("\n")
 	}
 
 	return s.String()
 }
 
+// perspective is the color drawn at the bottom of the board: the player's
+// own, or White for spectators, unless they flipped the board
+func (m model) perspective() Color {
+	color := White
+	switch {
+	case m.gameState == "puzzle" && m.puzzle != nil:
+		color = m.puzzle.color
+	case m.gameState == "replay" && m.replay != nil:
+		color, _ = recordOutcome(m.replay.record, m.player.Identity)
+	case m.gameState != "spectating" && m.gameSession != nil && m.player != nil:
+		color = m.player.Color
+	}
+	if m.flipped {
+		color = 1 - color
+	}
+	return color
+}
+
 func (m model) getBoardLines() []string {
 	var lines []string
+	showMover := !m.prefs.HideOpponentCursor
+
+	// Rows and columns from the top left corner as drawn
+	rows := []int{7, 6, 5, 4, 3, 2, 1, 0}
+	cols := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	if m.perspective() == Black {
+		slices.Reverse(rows)
+		slices.Reverse(cols)
+	}
 
-	lines = append(lines, "  a  b  c  d  e  f  g  h  ")
+	layout := m.layout()
+	pad := func(content string) string {
+		left := (layout.squareWidth - 1) / 2
+		return strings.Repeat(" ", left) + content + strings.Repeat(" ", layout.squareWidth-1-left)
+	}
 
-	for row := 7; row >= 0; row-- {
-		var line strings.Builder
-		line.WriteString(fmt.Sprintf("%d", row+1))
+	var files strings.Builder
+	files.WriteString(" ")
+	for _, col := range cols {
+		files.WriteString(pad(string(rune('a' + col))))
+	}
+	files.WriteString(" ")
 
-		for col := range 8 {
+	lines = append(lines, files.String())
+
+	theme, renderer := m.theme(), m.lipglossRenderer()
+	for _, row := range rows {
+		var squares [8]struct {
+			style lipgloss.Style
+			cell  string
+		}
+		for i, col := range cols {
 			pos := Position{row, col}
 			piece := m.game.Board.At(pos)
 
-			cellChar := piece.String()
-			if piece.Type == Empty {
-				cellChar = " "
-			}
-
-			// Determine background color
-			var bgColor string
+			// Determine what the square shows, most important first. a1 is a
+			// dark square.
+			light := (row+col)%2 == 1
+			var kind squareKind
 			if m.cursorRow == row && m.cursorCol == col {
-				bgColor = "\033[41m" // Red background for cursor
+				kind = cursorSquare
+			} else if showMover && m.moverCursor != nil && *m.moverCursor == pos {
+				kind = moverCursorSquare
 			} else if m.selected != nil && m.selected.Row == row && m.selected.Col == col {
-				bgColor = "\033[43m" // Yellow background for selected
+				kind = selectedSquare
 			} else if slices.Contains(m.validMoves, pos) {
-				bgColor = "\033[42m" // Green background for valid moves
-			} else if (row+col)%2 == 0 {
-				bgColor = "\033[100m" // Light grey background for light squares
+				kind = validMoveSquare
+			} else if showMover && m.moverSelected != nil && *m.moverSelected == pos {
+				kind = moverSelectedSquare
+			} else if showMover && slices.Contains(m.moverMoves, pos) {
+				kind = moverMoveSquare
+			} else if m.premoved(pos) {
+				kind = premoveSquare
+			} else if piece.Type == King && piece.Color == m.game.CurrentTurn && m.game.IsInCheck(piece.Color) {
+				kind = checkSquare
+			} else if m.game.lastMoveSquares(pos) {
+				kind = lastMoveSquare
+			} else if light {
+				kind = lightSquare
 			} else {
-				bgColor = "\033[40m" // Black background for dark squares
+				kind = darkSquare
 			}
 
-			line.WriteString(fmt.Sprintf("%s %s \033[0m", bgColor, cellChar))
+			squares[i].style = theme.style(renderer, kind, light)
+			squares[i].cell = pad(m.glyph(piece))
+			if marks, ok := squareMarks[kind]; ok && !styled(renderer) && layout.squareWidth >= 3 {
+				squares[i].cell = marks[0] + squares[i].cell[1:len(squares[i].cell)-1] + marks[1]
+			}
 		}
 
-		line.WriteString(fmt.Sprintf("%d", row+1))
-		lines = append(lines, line.String())
+		// Tall squares show the piece and rank number on their middle line
+		for i := range layout.squareHeight {
+			middle := i == layout.squareHeight/2
+			rank := " "
+			if middle {
+				rank = fmt.Sprintf("%d", row+1)
+			}
+
+			var line strings.Builder
+			line.WriteString(rank)
+			for _, square := range squares {
+				cell := square.cell
+				if !middle {
+					cell = strings.Repeat(" ", layout.squareWidth)
+				}
+				line.WriteString(square.style.Render(cell))
+			}
+			line.WriteString(rank)
+			lines = append(lines, line.String())
+		}
 	}
 
-	lines = append(lines, "  a  b  c  d  e  f  g  h  ")
+	lines = append(lines, files.String())
 
 	return lines
 }
@@ -403,6 +1278,14 @@ func (m model) getInfoLines() []string {
 	lines = append(lines, "│ GAME INFO           │")
 	lines = append(lines, "├─────────────────────┤")
 	lines = append(lines, fmt.Sprintf("│ Turn: %-13s │", m.game.CurrentTurn))
+	if m.gameSession != nil {
+		lines = append(lines, fmt.Sprintf("│ Time: %-13s │", m.gameSession.TimeControl))
+		if remaining, ok := m.gameSession.ClockRemaining(White); ok {
+			lines = append(lines, fmt.Sprintf("│ White: %-12s │", formatClock(remaining)))
+			remaining, _ = m.gameSession.ClockRemaining(Black)
+			lines = append(lines, fmt.Sprintf("│ Black: %-12s │", formatClock(remaining)))
+		}
+	}
 	lines = append(lines, "│                     │")
 
 	cursorPos := Position{m.cursorRow, m.cursorCol}
@@ -416,15 +1299,16 @@ func (m model) getInfoLines() []string {
 		lines = append(lines, fmt.Sprintf("│ Piece: %-12s │", pieceName))
 	}
 
+	if len(m.premoves) > 0 {
+		lines = append(lines, fmt.Sprintf("│ Premoves: %-9d │", len(m.premoves)))
+	}
+	if m.spectatorCount > 0 && m.gameState != "spectating" {
+		lines = append(lines, fmt.Sprintf("│ Spectators: %-7d │", m.spectatorCount))
+	}
 	lines = append(lines, "│                     │")
 
 	lines = append(lines, "└─────────────────────┘")
 
-	if len(m.game.MoveHistory) > 0 {
-		lastMove := m.game.MoveHistory[len(m.game.MoveHistory)-1]
-		lines = append(lines, fmt.Sprintf("Last move: %s -> %-12s", lastMove.From.String(), lastMove.To.String()))
-	}
-
 	return lines
 }
 
@@ -496,10 +1380,42 @@ func generateOrLoadHostKey(keyPath string) ([]byte, error) {
 	return keyPEM, nil
 }
 
+// sessionModel connects the player on an SSH session, using the account
+// bound to their key if any, and starts them in the lobby
+func sessionModel(s ssh.Session) model {
+	name, identity, _ := resolveIdentity(s)
+	player := &Player{
+		ID:         fmt.Sprintf("player_%d", time.Now().UnixNano()),
+		Session:    s,
+		Name:       name,
+		Identity:   identity,
+		Connected:  true,
+		UpdateChan: make(chan GameUpdate, 10),
+	}
+
+	m := initialModelWithPlayer(player)
+	GetGameManager().Connect(player)
+	m.lobby = GetGameManager().Lobby()
+	m.correspondence = GetGameManager().CorrespondenceGames(player.Identity)
+	m.tournaments = GetGameManager().Tournaments()
+
+	// Handle cleanup on session end
+	go func() {
+		<-s.Context().Done()
+		GetGameManager().RemovePlayer(player.ID)
+		if player.UpdateChan != nil {
+			close(player.UpdateChan)
+		}
+	}()
+	return m
+}
+
 func main() {
 	var (
-		sshPort = flag.Int("port", 2222, "SSH server port")
-		local   = flag.Bool("local", false, "run in local mode (generates/uses local host key instead of Secret Manager)")
+		sshPort       = flag.Int("port", 2222, "SSH server port")
+		local         = flag.Bool("local", false, "run in local mode (generates/uses local host key instead of Secret Manager)")
+		dataDir       = flag.String("data-dir", "", "directory for accounts, finished games and other persistent data (default ~/.chessh in local mode, a temp directory otherwise)")
+		gameRetention = flag.Duration("game-retention", 0, "how long to keep finished games, e.g. 2160h for 90 days (default forever)")
 	)
 	flag.Parse()
 
@@ -515,6 +1431,9 @@ func main() {
 		if err != nil {
 			log.Fatalf("failed to get user home directory: %v", err)
 		}
+		if *dataDir == "" {
+			*dataDir = filepath.Join(homeDir, ".chessh")
+		}
 		keyPath := filepath.Join(homeDir, ".chessh", "host_key")
 		hostKeyData, err = generateOrLoadHostKey(keyPath)
 		if err != nil {
@@ -538,37 +1457,66 @@ func main() {
 		log.Println("Running in cloud mode with Secret Manager")
 	}
 
+	if *dataDir == "" {
+		*dataDir = filepath.Join(os.TempDir(), "chessh")
+	}
+	accounts, err := NewFileAccountStore(filepath.Join(*dataDir, "accounts.json"))
+	if err != nil {
+		log.Fatalf("failed to open account store: %v", err)
+	}
+	GetGameManager().SetAccountStore(accounts)
+	games, err := NewFileGameStore(filepath.Join(*dataDir, "games"))
+	if err != nil {
+		log.Fatalf("failed to open game store: %v", err)
+	}
+	GetGameManager().SetGameStore(games)
+	go pruneGames(games, *gameRetention, ctx.Done())
+	ongoing, err := NewFileGameStore(filepath.Join(*dataDir, "correspondence"))
+	if err != nil {
+		log.Fatalf("failed to open correspondence game store: %v", err)
+	}
+	if err := GetGameManager().SetCorrespondenceStore(ongoing); err != nil {
+		log.Fatalf("failed to resume correspondence games: %v", err)
+	}
+	log.Printf("Storing data in %s", *dataDir)
+
 	s, err := wish.NewServer(
 		wish.WithAddress(fmt.Sprintf(":%d", *sshPort)),
 		wish.WithHostKeyPEM(hostKeyData),
+		wish.WithPublicKeyAuth(publicKeyHandler),
+		wish.WithKeyboardInteractiveAuth(keyboardInteractiveHandler),
 		wish.WithMiddleware(
 			bubbletea.Middleware(func(s ssh.Session) (tea.Model, []tea.ProgramOption) {
-				// Create player from SSH session
-				player := &Player{
-					ID:         fmt.Sprintf("player_%d", time.Now().UnixNano()),
-					Session:    s,
-					Name:       s.User(),
-					Connected:  true,
-					UpdateChan: make(chan GameUpdate, 10),
+				m := sessionModel(s)
+				m.renderer = bubbletea.MakeRenderer(s)
+
+				// The accessible mode writes plain lines, which the alternate
+				// screen and mouse reporting would only get in the way of
+				args := s.Command()
+				if len(args) > 0 && args[0] == "accessible" {
+					m.accessible = true
+					return m.startAccessible(args[1:]), []tea.ProgramOption{tea.WithInput(s), tea.WithOutput(s)}
 				}
 
-				// Create model with player
-				m := initialModelWithPlayer(player)
-
-				// Add player to matchmaking queue first
-				GetGameManager().AddPlayer(player)
-
-				// Handle cleanup on session end
-				go func() {
-					<-s.Context().Done()
-					GetGameManager().RemovePlayer(player.ID)
-					if player.UpdateChan != nil {
-						close(player.UpdateChan)
+				// Commands like `ssh -t host challenge alice` start with that action instead of the lobby
+				if len(args) > 0 {
+					switch args[0] {
+					case "challenge":
+						m = m.sendChallenge(args[1:])
+					case "room":
+						m = m.createRoom(args[1:])
+					case "join":
+						m = m.joinRoom(strings.Join(args[1:], ""))
+					case "watch":
+						m, _ = m.watch(strings.Join(args[1:], " "))
+					case "tournament":
+						m = m.createTournament(args[1:])
 					}
-				}()
+				}
 
-				return m, []tea.ProgramOption{tea.WithAltScreen(), tea.WithInput(s), tea.WithOutput(s)}
+				return m, []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithInput(s), tea.WithOutput(s)}
 			}),
+			commandMiddleware(),
 			logging.Middleware(),
 		),
 	)
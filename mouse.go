@@ -0,0 +1,117 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// mouseDrag tracks a mouse button held down over the board
+type mouseDrag struct {
+	from       Position
+	reselected bool // The press landed on the piece that was already selected
+}
+
+// boardSquare maps a mouse position to the board square under it, using
+// where View last drew the board and the layout of renderBoardWithInfo
+func (m model) boardSquare(x, y int) (Position, bool) {
+	if m.boardTop == nil || *m.boardTop < 0 {
+		return Position{}, false
+	}
+	top := *m.boardTop
+
+	// Below the file labels, each rank is a rank number then the squares
+	layout := m.layout()
+	if layout.tooSmall || y-top-1 < 0 || x < 1 {
+		return Position{}, false
+	}
+	row, col := (y-top-1)/layout.squareHeight, (x-1)/layout.squareWidth
+	if row > 7 || col > 7 {
+		return Position{}, false
+	}
+	if m.perspective() == Black {
+		return Position{row, 7 - col}, true
+	}
+	return Position{7 - row, col}, true
+}
+
+// handleMouse picks up and moves pieces with clicks, or by dragging them
+// where the terminal reports motion
+func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.inputPrompt != "" || m.showHelp {
+		return m, nil
+	}
+	switch m.gameState {
+	case "playing", "puzzle", "waiting", "opponent_disconnected", "spectating", "finished":
+	default:
+		return m, nil
+	}
+
+	pos, onBoard := m.boardSquare(msg.X, msg.Y)
+	switch msg.Action {
+	case tea.MouseActionPress:
+		if msg.Button != tea.MouseButtonLeft || !onBoard {
+			return m, nil
+		}
+		m.drag = &mouseDrag{from: pos, reselected: m.selected != nil && *m.selected == pos}
+		if m.drag.reselected {
+			// Wait for the release to tell a click, which puts the piece
+			// down, from the start of a drag
+			return m.moveCursorTo(pos), nil
+		}
+		return m.clickSquare(pos)
+
+	case tea.MouseActionMotion:
+		if m.drag != nil && onBoard {
+			return m.moveCursorTo(pos), nil
+		}
+
+	case tea.MouseActionRelease:
+		drag := m.drag
+		m.drag = nil
+		if drag == nil || !onBoard {
+			return m, nil
+		}
+		if pos == drag.from && drag.reselected {
+			return m.clickSquare(pos)
+		}
+		// Dropping a dragged piece on another square moves it there
+		if pos != drag.from && m.selected != nil && *m.selected == drag.from {
+			return m.clickSquare(pos)
+		}
+	}
+	return m, nil
+}
+
+// moveCursorTo puts the cursor on a square, showing it to the other side
+// when it's our move
+func (m model) moveCursorTo(pos Position) model {
+	if m.cursorRow == pos.Row && m.cursorCol == pos.Col {
+		return m
+	}
+	m.cursorRow, m.cursorCol = pos.Row, pos.Col
+	if m.gameState == "playing" && m.isMyTurn {
+		m.broadcastCursorUpdate()
+	}
+	return m
+}
+
+// clickSquare moves the cursor to a square and acts as if space was pressed
+// there, so a click selects a piece or moves the selected one
+func (m model) clickSquare(pos Position) (tea.Model, tea.Cmd) {
+	m = m.moveCursorTo(pos)
+
+	// Clicking another of our own pieces picks that one up instead
+	var mine Color
+	switch {
+	case m.gameState == "playing" && m.result == nil:
+		// Clicks during the opponent's turn queue premoves
+		mine = m.player.Color
+	case m.gameState == "puzzle" && m.puzzle != nil && !m.puzzle.solved && !m.puzzle.failed:
+		mine = m.puzzle.color
+	default:
+		return m, nil
+	}
+	if piece := m.game.Board.At(pos); m.selected != nil && *m.selected != pos && piece.Type != Empty && piece.Color == mine {
+		m.selected = nil
+		m.validMoves = make([]Position, 0)
+	}
+	// Straight to update, since the player's key bindings don't apply to clicks
+	return m.update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+}
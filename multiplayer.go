@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +19,7 @@ type Player struct {
 	Session    ssh.Session
 	Color      Color
 	Name       string
+	Identity   string // Account username, empty for guests
 	GameID     string
 	Connected  bool
 	UpdateChan chan GameUpdate // Channel for sending updates to the player's model
@@ -22,34 +27,105 @@ type Player struct {
 
 // GameUpdate represents an update to broadcast to players
 type GameUpdate struct {
-	Type       string      // "move", "cursor", "select", "gamestate"
-	Data       interface{} // The actual update data
-	FromPlayer string      // Which player sent the update
+	Type           string      // "move", "cursor", "select", "gamestate", "game_over", "chat"
+	Data           interface{} // The actual update data
+	FromPlayer     string      // Which player sent the update
+	SpectatorsOnly bool        // Keep the update from the players, e.g. spectator chat
+}
+
+// GameSettings are the options a game is played with
+type GameSettings struct {
+	TimeControl TimeControl
+	Variant     Variant
+	Rated       bool
+	FEN         string // Starting position, empty for the standard one
+	Private     bool   // Private games are hidden from everyone but their players
+}
+
+func (s GameSettings) String() string {
+	mode := "casual"
+	if s.Rated {
+		mode = "rated"
+	}
+	return fmt.Sprintf("%s %s, %s, %s", s.TimeControl, s.TimeControl.Category(), s.Variant.Title(), mode)
 }
 
 // GameSession manages a single game between two players
 type GameSession struct {
-	ID      string
-	Game    *Game
-	White   *Player
-	Black   *Player
-	Updates chan GameUpdate
-	ctx     context.Context
-	cancel  context.CancelFunc
-	mu      sync.RWMutex
+	GameSettings
+	ID            string
+	Game          *Game
+	White         *Player
+	Black         *Player
+	Clock         *Clock    // nil for untimed games
+	Ratings       [2]Rating // Ratings when the game started, indexed by color
+	Result        *GameResult
+	RatingChanges *[2]RatingChange // Set once a rated game has finished
+	Spectators    []*Player
+	Series        Series       // Score of the games these players have played in a row
+	Tournament    string       // Name of the tournament the game is part of, if any
+	rematchOffers [2]bool      // Which players want a rematch, by color
+	settings      GameSettings // As requested, before finish adjusts them
+	DrawOffer     *Color       // Who has offered a draw, if anyone
+	drawOffered   [2]int       // Move count at each side's last offer, plus one
+	Chat          []ChatMessage
+	chatTimes     map[string][]time.Time // Recent message times by sender, for rate limiting
+	cursors       map[string]GameUpdate  // Latest unsent cursor move by sender, see Broadcast
+	Started       time.Time
+	RecordID      string // The game's ID in storage, unique across restarts
+	Updates       chan GameUpdate
+	accounts      AccountStore // Where rated results are recorded
+	games         GameStore    // Where the game is saved once it's over
+	ongoing       GameStore    // Where unfinished correspondence games are kept
+	saveMu        sync.Mutex   // Orders writes to ongoing, so an old save can't land after a newer one
+	moveTimes     []time.Time  // When each move in Game.MoveHistory was played
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mu            sync.RWMutex
 }
 
-func NewGameSession(id string, white, black *Player) *GameSession {
+func NewGameSession(id string, white, black *Player, settings GameSettings, accounts AccountStore, games, ongoing GameStore) *GameSession {
 	ctx, cancel := context.WithCancel(context.Background())
+	started := time.Now()
+	// Session IDs start over when the server restarts, so add the start time
+	recordID := fmt.Sprintf("%s-%s", started.UTC().Format("20060102T150405"), id)
 
 	session := &GameSession{
-		ID:      id,
-		Game:    NewGame(),
-		White:   white,
-		Black:   black,
-		Updates: make(chan GameUpdate, 10),
-		ctx:     ctx,
-		cancel:  cancel,
+		GameSettings: settings,
+		settings:     settings,
+		ID:           id,
+		Game:         NewVariantGame(settings.Variant),
+		White:        white,
+		Black:        black,
+		Started:      started,
+		RecordID:     recordID,
+		chatTimes:    make(map[string][]time.Time),
+		cursors:      make(map[string]GameUpdate),
+		Updates:      make(chan GameUpdate, 10),
+		accounts:     accounts,
+		games:        games,
+		ongoing:      ongoing,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	if settings.FEN != "" {
+		// The position was validated when the game was set up
+		if game, err := NewGameFromFEN(settings.FEN); err == nil {
+			game.Variant = settings.Variant
+			session.Game = game
+		}
+	}
+	if !settings.TimeControl.Untimed() {
+		session.Clock = NewClock(settings.TimeControl)
+	}
+	if settings.TimeControl.Correspondence() {
+		// Players may not be online together, so the first move is timed too
+		session.Clock.Start(session.Game.CurrentTurn, started)
+	}
+	pool := ratingPool(settings)
+	session.Ratings = [2]Rating{
+		playerRating(accounts, white.Identity, pool),
+		playerRating(accounts, black.Identity, pool),
 	}
 
 	// Assign colors and game ID to players
@@ -65,12 +141,18 @@ func NewGameSession(id string, white, black *Player) *GameSession {
 }
 
 func (gs *GameSession) handleUpdates() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-gs.ctx.Done():
 			return
 		case update := <-gs.Updates:
 			gs.broadcastUpdate(update)
+		case now := <-ticker.C:
+			gs.flushCursors()
+			gs.checkFlag(now)
 		}
 	}
 }
@@ -79,6 +161,13 @@ func (gs *GameSession) broadcastUpdate(update GameUpdate) {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 
+	for _, spectator := range gs.Spectators {
+		notifyPlayer(spectator, update)
+	}
+	if update.SpectatorsOnly {
+		return
+	}
+
 	// Send update to both players (if connected) via their update channels
 	if gs.White != nil && gs.White.Connected && gs.White.UpdateChan != nil {
 		select {
@@ -96,6 +185,82 @@ func (gs *GameSession) broadcastUpdate(update GameUpdate) {
 	}
 }
 
+// AddSpectator lets a player watch the game read-only and tells the players
+// how many people are watching
+func (gs *GameSession) AddSpectator(p *Player) {
+	gs.mu.Lock()
+	gs.Spectators = append(gs.Spectators, p)
+	count := len(gs.Spectators)
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "spectators", Data: count})
+}
+
+// RemoveSpectator stops sending the game to a spectator
+func (gs *GameSession) RemoveSpectator(playerID string) {
+	gs.mu.Lock()
+	for i, spectator := range gs.Spectators {
+		if spectator.ID == playerID {
+			gs.Spectators = append(gs.Spectators[:i:i], gs.Spectators[i+1:]...)
+			break
+		}
+	}
+	count := len(gs.Spectators)
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "spectators", Data: count})
+}
+
+func (gs *GameSession) SpectatorCount() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return len(gs.Spectators)
+}
+
+// Broadcast queues an update for everyone in the game
+func (gs *GameSession) Broadcast(update GameUpdate) {
+	if update.Type == "cursor" {
+		// Cursor moves come with every keypress, so only the latest one is
+		// sent, on the next tick, to keep key repeat from flooding players
+		gs.mu.Lock()
+		gs.cursors[update.FromPlayer] = update
+		gs.mu.Unlock()
+		return
+	}
+	// Send any cursor move the sender made first, so updates stay in order
+	gs.mu.Lock()
+	cursor, pending := gs.cursors[update.FromPlayer]
+	delete(gs.cursors, update.FromPlayer)
+	gs.mu.Unlock()
+	if pending {
+		gs.enqueue(cursor)
+	}
+	gs.enqueue(update)
+}
+
+// flushCursors sends the latest cursor move of each player who has moved it
+// since the last tick
+func (gs *GameSession) flushCursors() {
+	gs.mu.Lock()
+	cursors := gs.cursors
+	gs.cursors = make(map[string]GameUpdate)
+	gs.mu.Unlock()
+
+	for _, cursor := range cursors {
+		gs.broadcastUpdate(cursor)
+	}
+}
+
+// enqueue hands an update to the broadcaster
+func (gs *GameSession) enqueue(update GameUpdate) {
+	select {
+	case gs.Updates <- update:
+	case <-gs.ctx.Done():
+	case <-time.After(100 * time.Millisecond):
+		// Drop update if channel is full
+	}
+}
+
 func (gs *GameSession) GetPlayer(playerID string) *Player {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
@@ -130,19 +295,282 @@ func (gs *GameSession) IsPlayerTurn(playerID string) bool {
 	return gs.Game.CurrentTurn == player.Color
 }
 
+// MakeMove plays a move for the given player if it's their turn and the move
+// is legal, then notifies the opponent and ends the game if it's over
+func (gs *GameSession) MakeMove(playerID string, from, to Position) bool {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return false
+	}
+
+	gs.mu.Lock()
+	if gs.Result != nil || gs.Game.CurrentTurn != player.Color || !gs.Game.MakeMove(from, to) {
+		gs.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	gs.moveTimes = append(gs.moveTimes, now)
+	if gs.Clock != nil {
+		gs.Clock.Press(player.Color, now)
+	}
+	// Moving instead of answering a draw offer declines it
+	gs.DrawOffer = nil
+	result := gs.Game.Result()
+	gs.mu.Unlock()
+
+	if gs.TimeControl.Correspondence() && result == nil {
+		gs.saveOngoing()
+	}
+
+	gs.Broadcast(GameUpdate{
+		Type: "move",
+		Data: map[string]interface{}{
+			"from":      from,
+			"to":        to,
+			"gameState": gs.Game,
+		},
+		FromPlayer: playerID,
+	})
+
+	if result != nil {
+		gs.finish(*result)
+	}
+	return true
+}
+
+// Resign ends the game as a loss for the player
+func (gs *GameSession) Resign(playerID string) error {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return errors.New("only players can resign")
+	}
+	if result, _ := gs.GetResult(); result != nil {
+		return errors.New("the game is already over")
+	}
+
+	gs.Broadcast(GameUpdate{Type: "resigned", Data: map[string]interface{}{"color": player.Color}})
+	gs.finish(GameResult{Outcome: Win(1 - player.Color), Reason: ReasonResignation})
+	return nil
+}
+
+// OfferDraw offers the opponent a draw. Each side may offer once per move.
+func (gs *GameSession) OfferDraw(playerID string) error {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return errors.New("only players can offer a draw")
+	}
+
+	gs.mu.Lock()
+	switch {
+	case gs.Result != nil:
+		gs.mu.Unlock()
+		return errors.New("the game is already over")
+	case gs.DrawOffer != nil && *gs.DrawOffer == player.Color:
+		gs.mu.Unlock()
+		return errors.New("you've already offered a draw")
+	case gs.DrawOffer != nil:
+		// Offering back is the same as accepting
+		gs.mu.Unlock()
+		return gs.AcceptDraw(playerID)
+	case gs.drawOffered[player.Color] == len(gs.Game.MoveHistory)+1:
+		gs.mu.Unlock()
+		return errors.New("wait for another move before offering again")
+	}
+	color := player.Color
+	gs.DrawOffer = &color
+	gs.drawOffered[color] = len(gs.Game.MoveHistory) + 1
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "draw_offered", Data: map[string]interface{}{"color": color}})
+	return nil
+}
+
+// AcceptDraw ends the game as a draw, if the opponent has offered one
+func (gs *GameSession) AcceptDraw(playerID string) error {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return errors.New("only players can accept a draw")
+	}
+
+	gs.mu.Lock()
+	if gs.Result != nil || gs.DrawOffer == nil || *gs.DrawOffer == player.Color {
+		gs.mu.Unlock()
+		return errors.New("there's no draw offer to accept")
+	}
+	gs.DrawOffer = nil
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "draw_accepted", Data: map[string]interface{}{"color": player.Color}})
+	gs.finish(GameResult{Outcome: Draw, Reason: ReasonAgreement})
+	return nil
+}
+
+// DeclineDraw turns down the opponent's draw offer
+func (gs *GameSession) DeclineDraw(playerID string) {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return
+	}
+
+	gs.mu.Lock()
+	if gs.DrawOffer == nil || *gs.DrawOffer == player.Color {
+		gs.mu.Unlock()
+		return
+	}
+	gs.DrawOffer = nil
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "draw_declined", Data: map[string]interface{}{"color": player.Color}})
+}
+
+// ClockRemaining returns the time left for color, or false for untimed games
+func (gs *GameSession) ClockRemaining(color Color) (time.Duration, bool) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	if gs.Clock == nil {
+		return 0, false
+	}
+	return gs.Clock.RemainingAt(color, time.Now()), true
+}
+
+// GetResult returns the result and rating changes, or nil while the game is in progress
+func (gs *GameSession) GetResult() (*GameResult, *[2]RatingChange) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.Result, gs.RatingChanges
+}
+
+func (gs *GameSession) checkFlag(now time.Time) {
+	gs.mu.RLock()
+	if gs.Clock == nil || gs.Result != nil {
+		gs.mu.RUnlock()
+		return
+	}
+	flagged, ok := gs.Clock.Flagged(now)
+	gs.mu.RUnlock()
+
+	if ok {
+		gs.finish(GameResult{Outcome: Win(1 - flagged), Reason: ReasonTimeout})
+	}
+}
+
+// finish records the result, then in the background updates ratings for
+// rated games, saves the game and tells both players the game is over. Only
+// the first result counts.
+func (gs *GameSession) finish(result GameResult) {
+	gs.mu.Lock()
+	if gs.Result != nil {
+		gs.mu.Unlock()
+		return
+	}
+	gs.Result = &result
+	gs.Series = gs.Series.withResult(result, gs.White.ID, gs.Black.ID)
+	gs.DrawOffer = nil
+	if gs.Clock != nil {
+		gs.Clock.Stop(time.Now())
+	}
+	// Games abandoned before both sides have moved don't count
+	if len(gs.Game.MoveHistory) < 2 {
+		gs.Rated = false
+	}
+	rated := gs.Rated
+	white, black := gs.White.Identity, gs.Black.Identity
+	gs.mu.Unlock()
+
+	// Saving ratings and the game writes files, which mustn't hold up callers
+	// like leaveGameLocked that hold gm.mu, so game_over follows once it's done
+	go gs.saveResult(result, rated, white, black)
+}
+
+// saveResult updates ratings for a rated game, stores the finished game and
+// tells everyone the game is over
+func (gs *GameSession) saveResult(result GameResult, rated bool, white, black string) {
+	data := map[string]interface{}{
+		"result": result,
+	}
+
+	if rated {
+		changes, err := applyRatings(gs.accounts, white, black, ratingPool(gs.GameSettings), result.Outcome)
+		if err != nil {
+			log.Printf("failed to update ratings for %s: %v", gs.ID, err)
+		} else {
+			gs.mu.Lock()
+			gs.RatingChanges = &changes
+			gs.mu.Unlock()
+			data["ratingChanges"] = changes
+		}
+	}
+
+	if gs.games != nil {
+		if err := gs.games.Save(gs.record()); err != nil {
+			log.Printf("failed to save game %s: %v", gs.ID, err)
+		}
+	}
+	if gs.TimeControl.Correspondence() && gs.ongoing != nil {
+		gs.saveMu.Lock()
+		if err := gs.ongoing.Delete(gs.RecordID); err != nil && !errors.Is(err, ErrGameNotFound) {
+			log.Printf("failed to remove finished correspondence game %s: %v", gs.ID, err)
+		}
+		gs.saveMu.Unlock()
+	}
+
+	gs.Broadcast(GameUpdate{
+		Type: "game_over",
+		Data: data,
+	})
+}
+
+// record describes the finished game for storage
+func (gs *GameSession) record() *GameRecord {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	record := &GameRecord{
+		ID:            gs.RecordID,
+		White:         RecordedPlayer{Name: gs.White.Name, Identity: gs.White.Identity},
+		Black:         RecordedPlayer{Name: gs.Black.Name, Identity: gs.Black.Identity},
+		TimeControl:   gs.TimeControl,
+		Variant:       gs.Variant,
+		Rated:         gs.Rated,
+		FEN:           gs.FEN,
+		Private:       gs.Private,
+		RatingChanges: gs.RatingChanges,
+		Started:       gs.Started,
+		Ended:         time.Now(),
+	}
+	if gs.Result != nil {
+		record.Outcome, record.Reason = gs.Result.Outcome, gs.Result.Reason
+	}
+	for i, move := range gs.Game.MoveHistory {
+		record.Moves = append(record.Moves, RecordedMove{
+			Move: move.From.String() + move.To.String(),
+			Time: gs.moveTimes[i],
+		})
+	}
+	return record
+}
+
 func (gs *GameSession) Disconnect(playerID string) {
+	if gs.TimeControl.Correspondence() && gs.resultMissing() {
+		// Correspondence players come and go; the game waits for them
+		gs.detach(playerID)
+		return
+	}
+
 	gs.mu.Lock()
-	defer gs.mu.Unlock()
 
 	var disconnectedPlayer, remainingPlayer *Player
 
+	// Seats keep a detached copy of the player, so a player who has moved on
+	// to another game stops receiving updates from this one
 	if gs.White != nil && gs.White.ID == playerID {
-		gs.White.Connected = false
+		gs.White = detachedPlayer(gs.White)
 		disconnectedPlayer = gs.White
 		remainingPlayer = gs.Black
 	}
 	if gs.Black != nil && gs.Black.ID == playerID {
-		gs.Black.Connected = false
+		gs.Black = detachedPlayer(gs.Black)
 		disconnectedPlayer = gs.Black
 		remainingPlayer = gs.White
 	}
@@ -161,22 +589,80 @@ func (gs *GameSession) Disconnect(playerID string) {
 		}
 	}
 
+	bothGone := (gs.White == nil || !gs.White.Connected) && (gs.Black == nil || !gs.Black.Connected)
+	gs.mu.Unlock()
+
+	// Leaving a game in progress forfeits it
+	if disconnectedPlayer != nil {
+		gs.finish(GameResult{Outcome: Win(1 - disconnectedPlayer.Color), Reason: ReasonAbandonment})
+	}
+
 	// If both players disconnected, cleanup
-	if (gs.White == nil || !gs.White.Connected) && (gs.Black == nil || !gs.Black.Connected) {
+	if bothGone {
 		gs.cleanup()
 	}
 }
 
+// Abandoned reports whether neither player is still connected
+func (gs *GameSession) Abandoned() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return (gs.White == nil || !gs.White.Connected) && (gs.Black == nil || !gs.Black.Connected)
+}
+
+// detachedPlayer returns a disconnected copy of p that can't receive updates
+func detachedPlayer(p *Player) *Player {
+	detached := *p
+	detached.Session = nil
+	detached.UpdateChan = nil
+	detached.Connected = false
+	return &detached
+}
+
+// notifyPlayer sends an update straight to one player, dropping it if their channel is full
+func notifyPlayer(p *Player, update GameUpdate) {
+	if p == nil || p.UpdateChan == nil {
+		return
+	}
+	select {
+	case p.UpdateChan <- update:
+	default:
+	}
+}
+
 func (gs *GameSession) cleanup() {
+	// Updates is left open so late broadcasts can't panic; they're dropped instead
 	gs.cancel()
-	close(gs.Updates)
+}
+
+// queueEntry is a player waiting in the matchmaking queue
+type queueEntry struct {
+	player   *Player
+	settings GameSettings
+	rating   Rating
+	joined   time.Time
+}
+
+// ratingWindow is how far apart two ratings may be for a player who has
+// waited this long. It starts narrow and widens so nobody waits forever.
+func ratingWindow(waited time.Duration) float64 {
+	return 100 + 20*waited.Seconds()
 }
 
 // GameManager handles matchmaking and game coordination
 type GameManager struct {
-	playerQueue  []*Player
+	players      map[string]*Player // Everyone connected, by player ID
+	playerQueue  []*queueEntry
 	activeGames  map[string]*GameSession
 	playerToGame map[string]string // playerID -> gameID
+	watching     map[string]string // spectator playerID -> gameID
+	challenges   map[string]*Challenge
+	rooms        map[string]*Room        // Private rooms by join code, kept out of the public queue
+	guestColors  map[string]ColorHistory // Color history of guests, by player ID
+	accounts     AccountStore
+	games        GameStore
+	ongoing      GameStore // Unfinished correspondence games
+	tournaments  map[string]*Tournament
 	mu           sync.RWMutex
 	gameCounter  int
 }
@@ -186,96 +672,328 @@ var gameManagerOnce sync.Once
 
 func GetGameManager() *GameManager {
 	gameManagerOnce.Do(func() {
-		gameManager = &GameManager{
-			playerQueue:  make([]*Player, 0),
-			activeGames:  make(map[string]*GameSession),
-			playerToGame: make(map[string]string),
-		}
+		gameManager = newGameManager()
+		go gameManager.matchLoop()
 	})
 	return gameManager
 }
 
-func (gm *GameManager) AddPlayer(player *Player) {
+// newGameManager returns an empty game manager with in-memory stores
+func newGameManager() *GameManager {
+	accounts, _ := NewFileAccountStore("") // in-memory until SetAccountStore is called
+	games, _ := NewFileGameStore("")       // in-memory until SetGameStore is called
+	ongoing, _ := NewFileGameStore("")     // in-memory until SetCorrespondenceStore is called
+	return &GameManager{
+		players:      make(map[string]*Player),
+		playerQueue:  make([]*queueEntry, 0),
+		activeGames:  make(map[string]*GameSession),
+		playerToGame: make(map[string]string),
+		watching:     make(map[string]string),
+		challenges:   make(map[string]*Challenge),
+		rooms:        make(map[string]*Room),
+		guestColors:  make(map[string]ColorHistory),
+		tournaments:  make(map[string]*Tournament),
+		accounts:     accounts,
+		games:        games,
+		ongoing:      ongoing,
+	}
+}
+
+// SetAccountStore replaces the store used to look up player identities
+func (gm *GameManager) SetAccountStore(accounts AccountStore) {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
+	gm.accounts = accounts
+}
+
+// SetGameStore replaces the store finished games are saved to
+func (gm *GameManager) SetGameStore(games GameStore) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.games = games
+}
+
+func (gm *GameManager) Games() GameStore {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	return gm.games
+}
+
+func (gm *GameManager) Accounts() AccountStore {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	return gm.accounts
+}
+
+// Connect registers a player as online so others can find them
+func (gm *GameManager) Connect(player *Player) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.players[player.ID] = player
+}
+
+// FindOnlinePlayer looks up a connected player by account username or display name
+func (gm *GameManager) FindOnlinePlayer(name string) *Player {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	for _, player := range gm.players {
+		if player.Identity != "" && player.Identity == name {
+			return player
+		}
+	}
+	for _, player := range gm.players {
+		if player.Name == name {
+			return player
+		}
+	}
+	return nil
+}
+
+// AddPlayer puts the player in the matchmaking queue for the given settings.
+// Whether the game is rated depends on both players having accounts.
+func (gm *GameManager) AddPlayer(player *Player, settings GameSettings) {
+	rating := playerRating(gm.Accounts(), player.Identity, ratingPool(settings))
 
-	// Add to queue
-	gm.playerQueue = append(gm.playerQueue, player)
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	// Add to queue, replacing any seek the player already had
+	gm.removeFromQueueLocked(player.ID)
+	gm.playerQueue = append(gm.playerQueue, &queueEntry{
+		player:   player,
+		settings: settings,
+		rating:   rating,
+		joined:   time.Now(),
+	})
 
 	// Try to match with another player
-	if len(gm.playerQueue) >= 2 {
-		white := gm.playerQueue[0]
-		black := gm.playerQueue[1]
+	gm.matchQueueLocked(time.Now())
+}
+
+// matchLoop periodically retries matchmaking, since acceptable rating
+// ranges widen as players wait
+func (gm *GameManager) matchLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
-		// Remove from queue
-		gm.playerQueue = gm.playerQueue[2:]
+	for now := range ticker.C {
+		gm.mu.Lock()
+		gm.matchQueueLocked(now)
+		gm.expireChallengesLocked(now)
+		gm.dropFinishedCorrespondenceLocked()
+		gm.runTournamentsLocked(now)
+		gm.mu.Unlock()
+	}
+}
 
-		// Create game
-		gm.gameCounter++
-		gameID := fmt.Sprintf("game_%d", gm.gameCounter)
+// matchQueueLocked pairs the closest-rated compatible players until no more
+// pairs fit within both players' rating windows. Callers must hold gm.mu.
+func (gm *GameManager) matchQueueLocked(now time.Time) {
+	for {
+		bestI, bestJ := -1, -1
+		bestDiff := math.Inf(1)
 
-		session := NewGameSession(gameID, white, black)
-		gm.activeGames[gameID] = session
-		gm.playerToGame[white.ID] = gameID
-		gm.playerToGame[black.ID] = gameID
+		for i := 0; i < len(gm.playerQueue); i++ {
+			for j := i + 1; j < len(gm.playerQueue); j++ {
+				a, b := gm.playerQueue[i], gm.playerQueue[j]
+				if a.settings.TimeControl != b.settings.TimeControl || a.settings.Variant != b.settings.Variant {
+					continue
+				}
+				diff := math.Abs(a.rating.Rating - b.rating.Rating)
+				window := math.Min(ratingWindow(now.Sub(a.joined)), ratingWindow(now.Sub(b.joined)))
+				if diff <= window && diff < bestDiff {
+					bestI, bestJ, bestDiff = i, j, diff
+				}
+			}
+		}
 
-		// Notify players they've been matched
-		matchUpdate := GameUpdate{
-			Type: "matched",
-			Data: map[string]any{
-				"gameID": gameID,
-				"opponent": map[string]string{
-					"white_opponent": black.Name,
-					"black_opponent": white.Name,
-				},
+		if bestI < 0 {
+			return
+		}
+
+		a, b := gm.playerQueue[bestI], gm.playerQueue[bestJ]
+		settings := a.settings
+		settings.Rated = canBeRated(a.player, b.player)
+		white, black := gm.assignColorsLocked(a.player, b.player, ColorRandom)
+		gm.startGameLocked(white, black, settings)
+	}
+}
+
+// canBeRated reports whether a game between a and b can affect ratings:
+// both need accounts, and nobody gets rated for playing themselves
+func canBeRated(a, b *Player) bool {
+	return a.Identity != "" && b.Identity != "" && a.Identity != b.Identity
+}
+
+// startGameLocked creates a session for two players and tells them they've
+// been matched. Both players leave the queue, their previous game and any
+// pending challenges. Callers must hold gm.mu.
+func (gm *GameManager) startGameLocked(white, black *Player, settings GameSettings) *GameSession {
+	for _, player := range []*Player{white, black} {
+		gm.stopWatchingLocked(player.ID)
+		gm.removeFromQueueLocked(player.ID)
+		gm.cancelChallengesLocked(player.ID)
+		gm.closeRoomsLocked(player.ID)
+		gm.leaveGameLocked(player.ID)
+	}
+
+	// Create game
+	gm.gameCounter++
+	gameID := fmt.Sprintf("game_%d", gm.gameCounter)
+
+	session := NewGameSession(gameID, white, black, settings, gm.accounts, gm.games, gm.ongoing)
+	gm.activeGames[gameID] = session
+	if settings.TimeControl.Correspondence() {
+		// Not while holding gm.mu, since it writes a file
+		go session.saveOngoing()
+	}
+	gm.playerToGame[white.ID] = gameID
+	gm.playerToGame[black.ID] = gameID
+	gm.recordColorsLocked(white, black)
+
+	// Notify players they've been matched
+	matchUpdate := GameUpdate{
+		Type: "matched",
+		Data: map[string]any{
+			"gameID": gameID,
+			"opponent": map[string]string{
+				"white_opponent": black.Name,
+				"black_opponent": white.Name,
 			},
+		},
+	}
+
+	// Send match update to both players via their channels
+	notifyPlayer(white, matchUpdate)
+	notifyPlayer(black, matchUpdate)
+
+	return session
+}
+
+func (gm *GameManager) RemovePlayer(playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	delete(gm.players, playerID)
+	delete(gm.guestColors, playerID)
+	gm.stopWatchingLocked(playerID)
+	gm.removeFromQueueLocked(playerID)
+	gm.cancelChallengesLocked(playerID)
+	gm.closeRoomsLocked(playerID)
+	gm.leaveGameLocked(playerID)
+	for _, t := range gm.tournaments {
+		if e := t.entrant(playerID); e != nil && t.Finished.IsZero() {
+			gm.withdrawLocked(t, playerID)
+			// Like a game's seats, the entry keeps a detached copy, since the
+			// player's update channel is closed once they've gone
+			e.Player = detachedPlayer(e.Player)
 		}
+	}
+}
+
+// Watch adds the player as a spectator of a game, found by room code for
+// private games or by game ID or either player's name for public ones
+func (gm *GameManager) Watch(target string, spectator *Player) (*GameSession, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
 
-		// Send match update to both players via their channels
-		if white.UpdateChan != nil {
-			select {
-			case white.UpdateChan <- matchUpdate:
-			default:
+	if gm.inGameLocked(spectator.ID) {
+		return nil, fmt.Errorf("finish your current game before watching another")
+	}
+
+	var session *GameSession
+	if room, exists := gm.rooms[strings.ToUpper(target)]; exists && room.GameID != "" {
+		session = gm.activeGames[room.GameID]
+	} else if game, exists := gm.activeGames[target]; exists && !game.Private {
+		session = game
+	} else {
+		for _, player := range gm.players {
+			if player.Identity == target || player.Name == target {
+				if gameID, playing := gm.playerToGame[player.ID]; playing {
+					session = gm.activeGames[gameID]
+					break
+				}
 			}
 		}
-		if black.UpdateChan != nil {
-			select {
-			case black.UpdateChan <- matchUpdate:
-			default:
-			}
+		if session != nil && session.Private {
+			return nil, fmt.Errorf("%s is playing a private game; ask them for the room code", target)
 		}
 	}
+	if session == nil {
+		return nil, fmt.Errorf("no game found for %q", target)
+	}
+
+	gm.stopWatchingLocked(spectator.ID)
+	gm.removeFromQueueLocked(spectator.ID)
+	gm.watching[spectator.ID] = session.ID
+	session.AddSpectator(spectator)
+	return session, nil
 }
 
-func (gm *GameManager) RemovePlayer(playerID string) {
+// StopWatching removes the player from the game they're spectating
+func (gm *GameManager) StopWatching(playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.stopWatchingLocked(playerID)
+}
+
+// stopWatchingLocked removes the player from the game they're spectating,
+// if any. Callers must hold gm.mu.
+func (gm *GameManager) stopWatchingLocked(playerID string) {
+	gameID, exists := gm.watching[playerID]
+	if !exists {
+		return
+	}
+	delete(gm.watching, playerID)
+	if session, exists := gm.activeGames[gameID]; exists {
+		session.RemoveSpectator(playerID)
+	}
+}
+
+// RemoveFromQueue takes the player out of the matchmaking queue
+func (gm *GameManager) RemoveFromQueue(playerID string) {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
+	gm.removeFromQueueLocked(playerID)
+}
 
-	// Remove from queue if present
-	for i, player := range gm.playerQueue {
-		if player.ID == playerID {
+// removeFromQueueLocked takes the player out of the matchmaking queue if
+// they're in it. Callers must hold gm.mu.
+func (gm *GameManager) removeFromQueueLocked(playerID string) {
+	for i, entry := range gm.playerQueue {
+		if entry.player.ID == playerID {
 			gm.playerQueue = append(gm.playerQueue[:i], gm.playerQueue[i+1:]...)
-			break
+			return
 		}
 	}
+}
 
-	// Handle active game disconnection
-	if gameID, exists := gm.playerToGame[playerID]; exists {
-		if session, gameExists := gm.activeGames[gameID]; gameExists {
-			session.Disconnect(playerID)
-
-			// Clean up if game is over
-			if (session.White == nil || !session.White.Connected) &&
-				(session.Black == nil || !session.Black.Connected) {
-				delete(gm.activeGames, gameID)
-				delete(gm.playerToGame, session.White.ID)
-				delete(gm.playerToGame, session.Black.ID)
-			}
+// leaveGameLocked disconnects the player from their current game, and
+// forgets the game once nobody is left in it. Callers must hold gm.mu.
+func (gm *GameManager) leaveGameLocked(playerID string) {
+	gameID, exists := gm.playerToGame[playerID]
+	if !exists {
+		return
+	}
+	delete(gm.playerToGame, playerID)
+
+	if session, gameExists := gm.activeGames[gameID]; gameExists {
+		session.Disconnect(playerID)
+		if session.Abandoned() && !session.waitingForPlayers() {
+			delete(gm.activeGames, gameID)
+			gm.expireRoomCodesLocked(gameID)
 		}
-		delete(gm.playerToGame, playerID)
 	}
 }
 
+// LeaveGame disconnects the player from their current game, if any
+func (gm *GameManager) LeaveGame(playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.leaveGameLocked(playerID)
+}
+
 func (gm *GameManager) GetGameSession(playerID string) *GameSession {
 	gm.mu.RLock()
 	defer gm.mu.RUnlock()
@@ -290,11 +1008,7 @@ func (gm *GameManager) BroadcastUpdate(playerID string, update GameUpdate) {
 	session := gm.GetGameSession(playerID)
 	if session != nil {
 		update.FromPlayer = playerID
-		select {
-		case session.Updates <- update:
-		case <-time.After(100 * time.Millisecond):
-			// Drop update if channel is full
-		}
+		session.Broadcast(update)
 	}
 }
 
@@ -302,8 +1016,8 @@ func (gm *GameManager) GetQueuePosition(playerID string) int {
 	gm.mu.RLock()
 	defer gm.mu.RUnlock()
 
-	for i, player := range gm.playerQueue {
-		if player.ID == playerID {
+	for i, entry := range gm.playerQueue {
+		if entry.player.ID == playerID {
 			return i + 1
 		}
 	}
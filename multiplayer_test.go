@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// startTestGame connects two guests and starts a casual game between them
+func startTestGame(t *testing.T, gm *GameManager, settings GameSettings) (session *GameSession, white, black *Player) {
+	t.Helper()
+	players := connectTestPlayers(gm, 2)
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	return gm.startGameLocked(players[0], players[1], settings), players[0], players[1]
+}
+
+func TestDrawsAndResignation(t *testing.T) {
+	type step struct {
+		by      Color
+		action  string // offer, accept, decline, resign, or a move like e2e4
+		wantErr string // Part of the error, or "" for none
+	}
+	for _, tt := range []struct {
+		name       string
+		steps      []step
+		wantResult *GameResult
+	}{{
+		name:       "offer accepted",
+		steps:      []step{{White, "offer", ""}, {Black, "accept", ""}},
+		wantResult: &GameResult{Outcome: Draw, Reason: ReasonAgreement},
+	}, {
+		name:       "offer made back",
+		steps:      []step{{White, "offer", ""}, {Black, "offer", ""}},
+		wantResult: &GameResult{Outcome: Draw, Reason: ReasonAgreement},
+	}, {
+		name:  "offering twice",
+		steps: []step{{White, "offer", ""}, {White, "offer", "already offered"}},
+	}, {
+		name:  "accepting your own offer",
+		steps: []step{{White, "offer", ""}, {White, "accept", "no draw offer"}},
+	}, {
+		name:  "accepting without an offer",
+		steps: []step{{Black, "accept", "no draw offer"}},
+	}, {
+		name:  "offering again before another move",
+		steps: []step{{White, "offer", ""}, {Black, "decline", ""}, {White, "offer", "wait for another move"}, {Black, "accept", "no draw offer"}},
+	}, {
+		name: "offering again after moves",
+		steps: []step{
+			{White, "offer", ""}, {Black, "decline", ""}, {White, "e2e4", ""}, {Black, "e7e5", ""},
+			{White, "offer", ""}, {Black, "accept", ""},
+		},
+		wantResult: &GameResult{Outcome: Draw, Reason: ReasonAgreement},
+	}, {
+		name:  "moving withdraws the offer",
+		steps: []step{{White, "offer", ""}, {White, "e2e4", ""}, {Black, "accept", "no draw offer"}},
+	}, {
+		name:       "resigning",
+		steps:      []step{{Black, "resign", ""}},
+		wantResult: &GameResult{Outcome: WhiteWins, Reason: ReasonResignation},
+	}, {
+		name:       "resigning with a draw offer open",
+		steps:      []step{{White, "offer", ""}, {White, "resign", ""}, {Black, "accept", "no draw offer"}},
+		wantResult: &GameResult{Outcome: BlackWins, Reason: ReasonResignation},
+	}, {
+		name:       "accepting, then resigning",
+		steps:      []step{{White, "offer", ""}, {Black, "accept", ""}, {Black, "resign", "already over"}, {White, "resign", "already over"}},
+		wantResult: &GameResult{Outcome: Draw, Reason: ReasonAgreement},
+	}, {
+		name:       "offering after the game",
+		steps:      []step{{White, "resign", ""}, {Black, "offer", "already over"}},
+		wantResult: &GameResult{Outcome: BlackWins, Reason: ReasonResignation},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			session, white, black := startTestGame(t, newGameManager(), GameSettings{TimeControl: DefaultTimeControl})
+			for i, s := range tt.steps {
+				player := white
+				if s.by == Black {
+					player = black
+				}
+				var err error
+				switch s.action {
+				case "offer":
+					err = session.OfferDraw(player.ID)
+				case "accept":
+					err = session.AcceptDraw(player.ID)
+				case "decline":
+					session.DeclineDraw(player.ID)
+				case "resign":
+					err = session.Resign(player.ID)
+				default:
+					from, to, _ := parseCoordinateMove(s.action)
+					if !session.MakeMove(player.ID, from, to) {
+						t.Fatalf("step %d: %s isn't legal", i+1, s.action)
+					}
+				}
+				switch {
+				case s.wantErr == "" && err != nil:
+					t.Fatalf("step %d: %s %s: %v", i+1, s.by, s.action, err)
+				case s.wantErr != "" && (err == nil || !strings.Contains(err.Error(), s.wantErr)):
+					t.Fatalf("step %d: %s %s: error %v, want one mentioning %q", i+1, s.by, s.action, err, s.wantErr)
+				}
+			}
+
+			result, _ := session.GetResult()
+			switch {
+			case tt.wantResult == nil && result != nil:
+				t.Errorf("result = %v, want the game still going", result)
+			case tt.wantResult != nil && (result == nil || *result != *tt.wantResult):
+				t.Errorf("result = %v, want %v", result, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestFirstResultCounts(t *testing.T) {
+	session, white, black := startTestGame(t, newGameManager(), GameSettings{TimeControl: DefaultTimeControl})
+	if err := session.OfferDraw(white.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both sides end the game at once; whichever gets there first decides it
+	done := make(chan error, 2)
+	go func() { done <- session.AcceptDraw(black.ID) }()
+	go func() { done <- session.Resign(white.ID) }()
+	<-done
+	<-done
+
+	first, _ := session.GetResult()
+	if first == nil {
+		t.Fatal("the game didn't end")
+	}
+	session.finish(GameResult{Outcome: BlackWins, Reason: ReasonTimeout})
+	if result, _ := session.GetResult(); *result != *first {
+		t.Errorf("result changed from %v to %v", first, result)
+	}
+}
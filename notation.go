@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// moveListRows is how many full moves the move list shows at once
+	moveListRows = 8
+	// moveListWidth is the width of the move list column, in cells, enough
+	// for every piece a side can capture and the material balance
+	moveListWidth = 26
+)
+
+// pieceLetters are the piece letters used in standard algebraic notation
+var pieceLetters = map[PieceType]string{
+	Knight: "N",
+	Bishop: "B",
+	Rook:   "R",
+	Queen:  "Q",
+	King:   "K",
+}
+
+// san writes a move in standard algebraic notation, without the check or
+// mate suffix, from the position before it is played
+func (g *Game) san(from, to Position) string {
+	piece := g.Board.At(from)
+	if piece.Type == King && abs(to.Col-from.Col) == 2 {
+		if to.Col > from.Col {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+
+	capture := g.Board.At(to).Type != Empty || (piece.Type == Pawn && from.Col != to.Col)
+	var s strings.Builder
+	if piece.Type == Pawn {
+		if capture {
+			s.WriteByte(byte('a' + from.Col))
+		}
+	} else {
+		s.WriteString(pieceLetters[piece.Type])
+		s.WriteString(g.disambiguation(piece, from, to))
+	}
+	if capture {
+		s.WriteString("x")
+	}
+	s.WriteString(to.String())
+	return s.String()
+}
+
+// disambiguation returns the file, rank or square needed to tell a move
+// apart from the same kind of piece moving to the same square
+func (g *Game) disambiguation(piece Piece, from, to Position) string {
+	sameFile, sameRank, ambiguous := false, false, false
+	for row := range 8 {
+		for col := range 8 {
+			other := Position{row, col}
+			if other == from || g.Board.At(other) != piece {
+				continue
+			}
+			if _, ok := g.Clone().play(other, to); !ok {
+				continue
+			}
+			ambiguous = true
+			sameFile = sameFile || other.Col == from.Col
+			sameRank = sameRank || other.Row == from.Row
+		}
+	}
+
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(rune('a' + from.Col))
+	case !sameRank:
+		return fmt.Sprintf("%d", from.Row+1)
+	}
+	return from.String()
+}
+
+// material adds up the value of color's pieces, in pawns
+func (g *Game) material(color Color) int {
+	total := 0
+	for row := range 8 {
+		for col := range 8 {
+			piece := g.Board[row][col]
+			if piece.Type != Empty && piece.Color == color {
+				total += pieceValues[piece.Type] / 100
+			}
+		}
+	}
+	return total
+}
+
+// capturedBy lists the pieces color has taken, in the order they were taken
+func (g *Game) capturedBy(color Color) []Piece {
+	var captured []Piece
+	for _, move := range g.MoveHistory {
+		if move.Piece.Color == color && move.Captured.Type != Empty {
+			captured = append(captured, move.Captured)
+		}
+	}
+	return captured
+}
+
+// lastMoveSquares reports whether pos is where the last move came from or went to
+func (g *Game) lastMoveSquares(pos Position) bool {
+	if len(g.MoveHistory) == 0 {
+		return false
+	}
+	last := g.MoveHistory[len(g.MoveHistory)-1]
+	return pos == last.From || pos == last.To
+}
+
+// moveRows pairs the game's moves up by move number. A game set up with
+// Black to move starts with an empty White move.
+func (g *Game) moveRows() [][2]string {
+	var rows [][2]string
+	for _, move := range g.MoveHistory {
+		if move.Piece.Color == White || len(rows) == 0 {
+			rows = append(rows, [2]string{"...", ""})
+		}
+		rows[len(rows)-1][move.Piece.Color] = move.SAN
+	}
+	return rows
+}
+
+// getMoveListLines shows the game's moves in two columns, the latest at the
+// bottom unless the player has scrolled back, and what each side has captured
+func (m model) getMoveListLines() []string {
+	rows := m.game.moveRows()
+	end := max(0, len(rows)-m.moveScroll)
+	start := max(0, end-moveListRows)
+
+	lines := []string{"MOVES"}
+	if len(rows) > moveListRows {
+		lines[0] = "MOVES (PgUp/PgDn)"
+	}
+	for i := start; i < end; i++ {
+		lines = append(lines, fmt.Sprintf("%3d. %-8s %-8s", i+1, rows[i][White], rows[i][Black]))
+	}
+	for len(lines) < moveListRows+1 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "")
+	balance := m.game.material(White) - m.game.material(Black)
+	for _, color := range []Color{White, Black} {
+		line := fmt.Sprintf("%s: ", color)
+		for _, piece := range m.game.capturedBy(color) {
+			line += m.glyph(piece)
+		}
+		if balance > 0 && color == White || balance < 0 && color == Black {
+			line += fmt.Sprintf(" +%d", abs(balance))
+		}
+		lines = append(lines, line)
+	}
+
+	for i, line := range lines {
+		if width := utf8.RuneCountInString(line); width < moveListWidth {
+			lines[i] = line + strings.Repeat(" ", moveListWidth-width)
+		}
+	}
+	return lines
+}
+
+// scrollMoveList scrolls the move list back (positive) or forward by some moves
+func (m model) scrollMoveList(moves int) model {
+	rows := len(m.game.moveRows())
+	m.moveScroll = max(0, min(m.moveScroll+moves, rows-moveListRows))
+	return m
+}
+
+// parseMove finds the legal move a player typed, in algebraic notation like
+// "Nf3", "exd5" or "O-O", or as coordinates like "g1f3" or "g1-f3"
+func (g *Game) parseMove(text string) (Position, Position, error) {
+	typed := normalizeMove(text)
+	if from, to, err := parseCoordinateMove(strings.ToLower(typed)); err == nil {
+		return from, to, nil
+	}
+
+	moves := g.LegalMoves()
+	for _, move := range moves {
+		if normalizeMove(g.san(move.From, move.To)) == typed {
+			return move.From, move.To, nil
+		}
+	}
+	// Not everyone types capitals, so "nf3" will do when it can only mean one move
+	var matches []Move
+	for _, move := range moves {
+		if strings.EqualFold(normalizeMove(g.san(move.From, move.To)), typed) {
+			matches = append(matches, move)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0].From, matches[0].To, nil
+	}
+	return Position{}, Position{}, fmt.Errorf("%s isn't a legal move", strings.TrimSpace(text))
+}
+
+// normalizeMove drops the parts of a move players may or may not type:
+// capture marks, hyphens, check marks, annotations and promotion pieces
+func normalizeMove(s string) string {
+	s = strings.NewReplacer("x", "", ":", "", "-", "", "+", "", "#", "", "!", "", "?", "", "e.p.", "", "0", "O").
+		Replace(strings.TrimSpace(s))
+	if i := strings.Index(s, "="); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
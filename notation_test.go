@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestSAN(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fen  string
+		move string
+		want string
+	}{
+		{"pawn push", startFEN, "e2e4", "e4"},
+		{"knight move", startFEN, "g1f3", "Nf3"},
+		{"pawn capture", "4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4d5", "exd5"},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+		{"piece capture", "4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1", "d1d5", "Rxd5"},
+		{"castling kingside", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"castling queenside", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"file disambiguation", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "b1d2", "Nbd2"},
+		{"rank disambiguation", "4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1a3", "R1a3"},
+		{"square disambiguation", "4k3/8/8/8/8/Q1Q5/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2"},
+		{"pinned piece isn't a rival", "4r2k/8/8/1N6/8/8/4N3/4K3 w - - 0 1", "b5d4", "Nd4"},
+		{"check", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8+"},
+		{"checkmate", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGameFromFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to, err := parseCoordinateMove(tt.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !g.MakeMove(from, to) {
+				t.Fatalf("%s isn't legal", tt.move)
+			}
+			if got := g.MoveHistory[len(g.MoveHistory)-1].SAN; got != tt.want {
+				t.Errorf("SAN = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMove(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fen  string
+		text string
+		want string // As coordinates, or "" for an error
+	}{
+		{"pawn push", startFEN, "e4", "e2e4"},
+		{"piece move", startFEN, "Nf3", "g1f3"},
+		{"lower case piece", startFEN, "nf3", "g1f3"},
+		{"coordinates", startFEN, "g1f3", "g1f3"},
+		{"coordinates with a hyphen", startFEN, "g1-f3", "g1f3"},
+		{"check mark and annotation", startFEN, " e4+!? ", "e2e4"},
+		{"capture without the x", "4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "ed5", "e4d5"},
+		{"castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"castling with zeros", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1"},
+		{"pawn, not bishop", "4k3/8/8/B7/8/2p5/1P6/4K3 w - - 0 1", "bxc3", "b2c3"},
+		{"bishop, not pawn", "4k3/8/8/B7/8/2p5/1P6/4K3 w - - 0 1", "Bxc3", "a5c3"},
+		{"disambiguated", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "Nfd2", "f1d2"},
+		{"ambiguous", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "Nd2", ""},
+		{"illegal", startFEN, "e5", ""},
+		{"nonsense", startFEN, "hello", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGameFromFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to, err := g.parseMove(tt.text)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("parseMove(%q) = %s%s, want an error", tt.text, from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := from.String() + to.String(); got != tt.want {
+				t.Errorf("parseMove(%q) = %s, want %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
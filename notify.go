@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+)
+
+// alerts lists the ways of telling a player it's their move, for when
+// they've switched to another window, and the preference for each
+var alerts = []struct {
+	name, description string
+	pref              func(*Preferences) *bool
+}{
+	{"bell", "ring the terminal bell", func(p *Preferences) *bool { return &p.Bell }},
+	{"title", "show whose move it is in the window title", func(p *Preferences) *bool { return &p.Title }},
+	{"desktop", "send a desktop notification, where the terminal supports them", func(p *Preferences) *bool { return &p.Desktop }},
+}
+
+// setAlerts turns on the alerts named in a line like "bell title" and the
+// rest off, remembering them for players with an account
+func (m model) setAlerts(input string) model {
+	prefs := m.prefs
+	for _, alert := range alerts {
+		*alert.pref(&prefs) = false
+	}
+next:
+	for _, word := range strings.Fields(strings.ToLower(input)) {
+		if word == "none" {
+			continue
+		}
+		for _, alert := range alerts {
+			if alert.name == word {
+				*alert.pref(&prefs) = true
+				continue next
+			}
+		}
+		m.notice = fmt.Sprintf("Unknown alert %q; the alerts are %s, or none.", word, strings.Join(alertNames(), ", "))
+		return m
+	}
+
+	m.prefs = prefs
+	m.notice = fmt.Sprintf("Alerts when it's your move: %s.", m.alertsSummary())
+	if err := savePreferences(GetGameManager().Accounts(), m.player.Identity, m.prefs); err != nil {
+		m.notice = fmt.Sprintf("Failed to save your preferences: %v", err)
+	}
+	return m
+}
+
+// alertNames lists the alerts' names
+func alertNames() []string {
+	var names []string
+	for _, alert := range alerts {
+		names = append(names, alert.name)
+	}
+	return names
+}
+
+// alertsSummary lists the alerts the player has turned on, e.g. "bell title"
+func (m model) alertsSummary() string {
+	var on []string
+	for _, alert := range alerts {
+		if *alert.pref(&m.prefs) {
+			on = append(on, alert.name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, " ")
+}
+
+// windowTitle is what the window title should say, if the player wants it
+// to follow the game
+func (m model) windowTitle() string {
+	switch {
+	case !m.prefs.Title:
+		return ""
+	case m.gameState == "playing" && m.opponent != nil && m.isMyTurn:
+		return fmt.Sprintf("♟ Your move vs %s", m.opponent.Name)
+	case m.gameState == "playing" && m.opponent != nil:
+		return fmt.Sprintf("CheSSH vs %s", m.opponent.Name)
+	}
+	return "CheSSH"
+}
+
+// notifyMove rings the bell and sends a desktop notification, as the player
+// chose, when the opponent's move makes it their turn
+func (m model) notifyMove() tea.Cmd {
+	if m.player == nil || m.player.Session == nil || m.opponent == nil {
+		return nil
+	}
+	var seq strings.Builder
+	if m.prefs.Bell {
+		seq.WriteString("\a")
+	}
+	if m.prefs.Desktop && len(m.game.MoveHistory) > 0 {
+		last := m.game.MoveHistory[len(m.game.MoveHistory)-1]
+		seq.WriteString(desktopNotification(m.player.Session, fmt.Sprintf("%s played %s. Your move.", m.opponent.Name, last.SAN)))
+	}
+	return m.writeSequence(seq.String())
+}
+
+// setWindowTitle sets the terminal's window title with OSC 2. Bubble Tea's
+// own SetWindowTitle command is ignored by the renderer in the version we use.
+func (m model) setWindowTitle(title string) tea.Cmd {
+	return m.writeSequence(fmt.Sprintf("\x1b]2;%s\x07", sanitizeChat(title)))
+}
+
+// writeSequence sends an escape sequence to the player's terminal alongside
+// what the renderer draws
+func (m model) writeSequence(seq string) tea.Cmd {
+	if seq == "" || m.player == nil || m.player.Session == nil {
+		return nil
+	}
+	session := m.player.Session
+	return func() tea.Msg {
+		_, _ = io.WriteString(session, seq)
+		return nil
+	}
+}
+
+// desktopNotification is the escape sequence that pops up a notification in
+// the session's terminal: OSC 777 for VTE based terminals, foot and urxvt,
+// and OSC 9 for iTerm2, WezTerm, Windows Terminal and most others
+func desktopNotification(s ssh.Session, text string) string {
+	text = sanitizeChat(text)
+	pty, _, _ := s.Pty()
+	vte := false
+	for _, env := range s.Environ() {
+		vte = vte || strings.HasPrefix(env, "VTE_VERSION=")
+	}
+	if vte || strings.HasPrefix(pty.Term, "foot") || strings.HasPrefix(pty.Term, "rxvt") {
+		return fmt.Sprintf("\x1b]777;notify;CheSSH;%s\x07", strings.ReplaceAll(text, ";", ","))
+	}
+	return fmt.Sprintf("\x1b]9;%s\x07", text)
+}
@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// openings maps well-known move sequences, in coordinate notation, to their names
+var openings = map[string]string{
+	"e2e4":                               "King's Pawn Opening",
+	"e2e4 e7e5":                          "Open Game",
+	"e2e4 e7e5 g1f3":                     "King's Knight Opening",
+	"e2e4 e7e5 g1f3 b8c6 f1b5":           "Ruy Lopez",
+	"e2e4 e7e5 g1f3 b8c6 f1c4":           "Italian Game",
+	"e2e4 e7e5 g1f3 b8c6 f1c4 g8f6":      "Two Knights Defense",
+	"e2e4 e7e5 g1f3 b8c6 f1c4 f8c5":      "Giuoco Piano",
+	"e2e4 e7e5 g1f3 b8c6 d2d4":           "Scotch Game",
+	"e2e4 e7e5 g1f3 g8f6":                "Petrov's Defense",
+	"e2e4 e7e5 g1f3 d7d6":                "Philidor Defense",
+	"e2e4 e7e5 f2f4":                     "King's Gambit",
+	"e2e4 e7e5 b1c3":                     "Vienna Game",
+	"e2e4 c7c5":                          "Sicilian Defense",
+	"e2e4 c7c5 g1f3 d7d6 d2d4 c5d4 f3d4": "Open Sicilian",
+	"e2e4 c7c5 g1f3 d7d6 d2d4 c5d4 f3d4 g8f6 b1c3 a7a6": "Sicilian Defense, Najdorf Variation",
+	"e2e4 e7e6":                     "French Defense",
+	"e2e4 c7c6":                     "Caro-Kann Defense",
+	"e2e4 d7d5":                     "Scandinavian Defense",
+	"e2e4 g8f6":                     "Alekhine's Defense",
+	"e2e4 d7d6":                     "Pirc Defense",
+	"e2e4 g7g6":                     "Modern Defense",
+	"d2d4":                          "Queen's Pawn Opening",
+	"d2d4 d7d5 c2c4":                "Queen's Gambit",
+	"d2d4 d7d5 c2c4 d5c4":           "Queen's Gambit Accepted",
+	"d2d4 d7d5 c2c4 e7e6":           "Queen's Gambit Declined",
+	"d2d4 d7d5 c2c4 c7c6":           "Slav Defense",
+	"d2d4 d7d5 c1f4":                "London System",
+	"d2d4 g8f6 c2c4 g7g6":           "King's Indian Defense",
+	"d2d4 g8f6 c2c4 e7e6 b1c3 f8b4": "Nimzo-Indian Defense",
+	"d2d4 g8f6 c2c4 e7e6 g1f3 b7b6": "Queen's Indian Defense",
+	"d2d4 g8f6 c2c4 c7c5":           "Benoni Defense",
+	"d2d4 f7f5":                     "Dutch Defense",
+	"c2c4":                          "English Opening",
+	"g1f3":                          "Réti Opening",
+	"g2g3":                          "King's Fianchetto Opening",
+	"b2b3":                          "Nimzo-Larsen Attack",
+	"f2f4":                          "Bird's Opening",
+}
+
+// openingName names the opening of a game that started from the standard
+// position, using the longest known sequence the moves begin with
+func openingName(moves []string) string {
+	// No sequence above is longer than ten moves
+	for n := min(len(moves), 10); n > 0; n-- {
+		if name, ok := openings[strings.Join(moves[:n], " ")]; ok {
+			return name
+		}
+	}
+	return ""
+}
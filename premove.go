@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// premove is a move queued during the opponent's turn, played as soon as
+// it's our turn if it's legal then
+type premove struct {
+	from, to Position
+}
+
+// premoveBoard is the board as it will be once the queued premoves are
+// played, leaving out the opponent's replies, which aren't known yet
+func (m model) premoveBoard() Board {
+	board := m.game.Board
+	for _, p := range m.premoves {
+		piece := board.At(p.from)
+		switch {
+		case piece.Type == King && abs(p.to.Col-p.from.Col) == 2:
+			// Castling, so the rook comes across too
+			rookFrom, rookTo := Position{p.from.Row, 0}, Position{p.from.Row, 3}
+			if p.to.Col > p.from.Col {
+				rookFrom, rookTo = Position{p.from.Row, 7}, Position{p.from.Row, 5}
+			}
+			if board.At(rookFrom) == (Piece{Rook, piece.Color}) {
+				board.Set(rookTo, board.At(rookFrom))
+				board.Set(rookFrom, Piece{Empty, White})
+			}
+		case piece.Type == Pawn && p.from.Col != p.to.Col && board.At(p.to).Type == Empty:
+			// A diagonal step onto an empty square takes en passant
+			passed := Position{p.from.Row, p.to.Col}
+			if board.At(passed) == (Piece{Pawn, 1 - piece.Color}) {
+				board.Set(passed, Piece{Empty, White})
+			}
+		}
+		board.Set(p.to, piece)
+		board.Set(p.from, Piece{Empty, White})
+	}
+	return board
+}
+
+// premoveGame is the game as it will be once the queued premoves are played,
+// with our side to move, for reading premoves typed in notation
+func (m model) premoveGame() *Game {
+	g := m.game.Clone()
+	g.Board = m.premoveBoard()
+	g.CurrentTurn = m.player.Color
+	return g
+}
+
+// premoved reports whether a queued premove starts or ends on pos
+func (m model) premoved(pos Position) bool {
+	return slices.ContainsFunc(m.premoves, func(p premove) bool {
+		return p.from == pos || p.to == pos
+	})
+}
+
+// selectPremove picks up a piece or queues a premove for it during the
+// opponent's turn, as space does during ours
+func (m model) selectPremove(pos Position) model {
+	board := m.premoveBoard()
+	piece := board.At(pos)
+	mine := piece.Type != Empty && piece.Color == m.player.Color
+	switch {
+	case m.selected == nil:
+		if mine {
+			m.selected = &pos
+		}
+	case *m.selected == pos:
+		m.selected = nil
+	case mine:
+		// Picking up another piece instead
+		m.selected = &pos
+	default:
+		m = m.queuePremove(*m.selected, pos)
+		m.selected = nil
+	}
+	return m
+}
+
+// queuePremove adds a move to play after the opponent's. It only has to
+// move one of our pieces somewhere we don't have one yet; whether it's
+// legal is up to the position once the opponent has moved.
+func (m model) queuePremove(from, to Position) model {
+	board := m.premoveBoard()
+	if piece := board.At(from); piece.Type == Empty || piece.Color != m.player.Color {
+		m.notice = fmt.Sprintf("You don't have a piece on %s to premove.", from)
+		return m
+	}
+	if piece := board.At(to); from == to || piece.Type != Empty && piece.Color == m.player.Color {
+		m.notice = fmt.Sprintf("Your premove can't go to %s.", to)
+		return m
+	}
+	m.premoves = append(m.premoves, premove{from, to})
+	return m
+}
+
+// playPremove plays the first queued premove once it's our turn. If it isn't
+// legal after the opponent's move, the whole queue is dropped, since the
+// moves after it were planned on it.
+func (m model) playPremove() model {
+	if len(m.premoves) == 0 || !m.isMyTurn || m.gameSession == nil {
+		return m
+	}
+	next := m.premoves[0]
+	if !m.gameSession.MakeMove(m.player.ID, next.from, next.to) {
+		m.notice = fmt.Sprintf("Your premove %s to %s isn't legal now, so your premoves were dropped.", next.from, next.to)
+		m.premoves = nil
+		return m
+	}
+	m.premoves = m.premoves[1:]
+	m.isMyTurn = false
+	m.selected = nil
+	m.validMoves = make([]Position, 0)
+	return m
+}
+
+// cancelPremoves drops the queued premoves and any piece picked up for one
+func (m model) cancelPremoves() model {
+	m.premoves = nil
+	m.selected = nil
+	return m
+}
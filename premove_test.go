@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestPremoveBoard(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		fen      string
+		premoves [][2]string
+		want     map[string]Piece
+	}{{
+		name:     "two moves in a row",
+		fen:      "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1",
+		premoves: [][2]string{{"e2", "e4"}, {"e4", "e5"}},
+		want:     map[string]Piece{"e2": {Empty, White}, "e4": {Empty, White}, "e5": {Pawn, White}},
+	}, {
+		name:     "castling kingside",
+		fen:      "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+		premoves: [][2]string{{"e1", "g1"}},
+		want:     map[string]Piece{"e1": {Empty, White}, "f1": {Rook, White}, "g1": {King, White}, "h1": {Empty, White}, "a1": {Rook, White}},
+	}, {
+		name:     "castling queenside",
+		fen:      "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+		premoves: [][2]string{{"e8", "c8"}},
+		want:     map[string]Piece{"a8": {Empty, White}, "c8": {King, Black}, "d8": {Rook, Black}, "e8": {Empty, White}, "h8": {Rook, Black}},
+	}, {
+		name:     "en passant",
+		fen:      "4k3/8/8/3pP3/8/8/8/4K3 b - - 0 1",
+		premoves: [][2]string{{"e5", "d6"}},
+		want:     map[string]Piece{"d5": {Empty, White}, "d6": {Pawn, White}, "e5": {Empty, White}},
+	}, {
+		name:     "capture beside a pawn",
+		fen:      "4k3/8/3n4/3pP3/8/8/8/4K3 b - - 0 1",
+		premoves: [][2]string{{"e5", "d6"}},
+		want:     map[string]Piece{"d5": {Pawn, Black}, "d6": {Pawn, White}},
+	}, {
+		name:     "diagonal step beside our own pawn",
+		fen:      "4k3/8/8/3PP3/8/8/8/4K3 b - - 0 1",
+		premoves: [][2]string{{"e5", "d6"}},
+		want:     map[string]Piece{"d5": {Pawn, White}, "d6": {Pawn, White}},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			game, err := NewGameFromFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := model{game: game}
+			for _, p := range tt.premoves {
+				from, _ := parseSquare(p[0])
+				to, _ := parseSquare(p[1])
+				m.premoves = append(m.premoves, premove{from, to})
+			}
+			board := m.premoveBoard()
+			for square, want := range tt.want {
+				pos, _ := parseSquare(square)
+				if got := board.At(pos); got != want {
+					t.Errorf("%s = %v, want %v", square, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// recentGames is how many of a player's latest games their profile lists
+	recentGames = 10
+	// favoriteOpenings is how many openings a profile lists
+	favoriteOpenings = 3
+	// ratingHistoryLength is how many rating changes the history graph shows
+	ratingHistoryLength = 40
+	// leaderboardSize is how many players a leaderboard shows
+	leaderboardSize = 20
+)
+
+// ratingPools lists every rating pool in display order: standard chess by
+// time control, then each variant
+func ratingPools() []string {
+	return []string{"bullet", "blitz", "rapid", "classical", "correspondence", "unlimited",
+		KingOfTheHill.String(), ThreeCheck.String()}
+}
+
+// poolTitle names a rating pool for display
+func poolTitle(pool string) string {
+	if variant, err := ParseVariant(pool); err == nil {
+		return variant.Title()
+	}
+	return strings.ToUpper(pool[:1]) + pool[1:]
+}
+
+// parsePool finds a rating pool by name, accepting variant aliases like "koth"
+func parsePool(name string) (string, error) {
+	name = strings.ToLower(name)
+	for _, pool := range ratingPools() {
+		if pool == name {
+			return pool, nil
+		}
+	}
+	if variant, err := ParseVariant(name); err == nil && variant != Standard {
+		return variant.String(), nil
+	}
+	return "", fmt.Errorf("unknown leaderboard %q, want one of %s", name, strings.Join(ratingPools(), ", "))
+}
+
+// Profile collects a player's ratings and record
+type Profile struct {
+	Username      string
+	Created       time.Time
+	LastSeen      time.Time
+	Ratings       map[string]Rating
+	History       map[string][]float64 // Rating after each stored rated game, oldest first, by pool
+	Wins          int
+	Losses        int
+	Draws         int
+	Openings      []string // Most played first
+	LongestStreak int      // Most wins in a row
+	Recent        []*GameRecord
+}
+
+// buildProfile looks up an account and summarizes the games it has played
+func buildProfile(accounts AccountStore, games GameStore, username string) (*Profile, error) {
+	account, err := accounts.Get(strings.ToLower(username))
+	if err != nil {
+		return nil, fmt.Errorf("no player named %q", username)
+	}
+	records, err := games.List(account.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Username: account.Username,
+		Created:  account.Created,
+		LastSeen: account.LastSeen,
+		Ratings:  account.Ratings,
+		History:  make(map[string][]float64),
+		Recent:   records[:min(len(records), recentGames)],
+	}
+
+	openings := make(map[string]int)
+	streak := 0
+	// Stored games are newest first
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		color, outcome := recordOutcome(record, account.Username)
+		switch outcome {
+		case "Won":
+			profile.Wins++
+			streak++
+			profile.LongestStreak = max(profile.LongestStreak, streak)
+		case "Lost":
+			profile.Losses++
+			streak = 0
+		default:
+			profile.Draws++
+			streak = 0
+		}
+		if opening := recordOpening(record); opening != "" {
+			openings[opening]++
+		}
+		if record.RatingChanges != nil {
+			pool := ratingPool(GameSettings{TimeControl: record.TimeControl, Variant: record.Variant})
+			profile.History[pool] = append(profile.History[pool], record.RatingChanges[color].After.Rating)
+		}
+	}
+
+	for opening := range openings {
+		profile.Openings = append(profile.Openings, opening)
+	}
+	sort.Slice(profile.Openings, func(i, j int) bool {
+		a, b := profile.Openings[i], profile.Openings[j]
+		if openings[a] != openings[b] {
+			return openings[a] > openings[b]
+		}
+		return a < b
+	})
+	profile.Openings = profile.Openings[:min(len(profile.Openings), favoriteOpenings)]
+	return profile, nil
+}
+
+// sparkline draws values as a row of bars scaled between their minimum and maximum
+func sparkline(values []float64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	var s strings.Builder
+	for _, v := range values {
+		i := 0
+		if high > low {
+			i = int((v - low) / (high - low) * float64(len(bars)-1))
+		}
+		s.WriteRune(bars[i])
+	}
+	return s.String()
+}
+
+// String formats the profile as plain text, for the TUI and the profile command
+func (p *Profile) String() string {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("%s, playing since %s, last seen %s\n\n", p.Username,
+		p.Created.Local().Format("2006-01-02"), p.LastSeen.Local().Format("2006-01-02")))
+
+	s.WriteString("Ratings\n")
+	rated := false
+	for _, pool := range ratingPools() {
+		rating, ok := p.Ratings[pool]
+		if !ok {
+			continue
+		}
+		rated = true
+		s.WriteString(fmt.Sprintf("  %-16s %6s  %4d games", poolTitle(pool), rating, rating.Games))
+		if history := p.History[pool]; len(history) > 1 {
+			history = history[max(0, len(history)-ratingHistoryLength):]
+			s.WriteString(fmt.Sprintf("  %s", sparkline(history)))
+		}
+		s.WriteString("\n")
+	}
+	if !rated {
+		s.WriteString("  No rated games yet\n")
+	}
+
+	total := p.Wins + p.Losses + p.Draws
+	s.WriteString(fmt.Sprintf("\nRecord: %d games, %d won, %d lost, %d drawn", total, p.Wins, p.Losses, p.Draws))
+	if p.LongestStreak > 1 {
+		s.WriteString(fmt.Sprintf("; longest winning streak %d", p.LongestStreak))
+	}
+	s.WriteString("\n")
+	if len(p.Openings) > 0 {
+		s.WriteString(fmt.Sprintf("Favorite openings: %s\n", strings.Join(p.Openings, ", ")))
+	}
+
+	if len(p.Recent) > 0 {
+		s.WriteString("\nRecent games\n")
+		for _, record := range p.Recent {
+			s.WriteString("  " + recordSummary(record, p.Username) + "\n")
+		}
+	}
+	return s.String()
+}
+
+// LeaderboardEntry is a player's place on a leaderboard
+type LeaderboardEntry struct {
+	Username string
+	Rating   Rating
+}
+
+// leaderboard ranks the accounts rated in pool, established ratings ahead
+// of provisional ones
+func leaderboard(accounts AccountStore, pool string, size int) ([]LeaderboardEntry, error) {
+	all, err := accounts.List()
+	if err != nil {
+		return nil, err
+	}
+	var entries []LeaderboardEntry
+	for _, account := range all {
+		if rating, ok := account.Ratings[pool]; ok {
+			entries = append(entries, LeaderboardEntry{Username: account.Username, Rating: rating})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Rating, entries[j].Rating
+		if a.Provisional() != b.Provisional() {
+			return !a.Provisional()
+		}
+		return a.Rating > b.Rating
+	})
+	return entries[:min(len(entries), size)], nil
+}
+
+// leaderboardText formats a leaderboard as plain text
+func leaderboardText(pool string, entries []LeaderboardEntry) string {
+	var s strings.Builder
+	s.WriteString(poolTitle(pool) + "\n")
+	if len(entries) == 0 {
+		s.WriteString("  Nobody has played a rated game yet\n")
+	}
+	for i, entry := range entries {
+		s.WriteString(fmt.Sprintf("%3d %-20s %6s  %4d games\n", i+1, entry.Username, entry.Rating, entry.Rating.Games))
+	}
+	return s.String()
+}
+
+// showProfile opens the profile screen for a username, or for the player themselves
+func (m model) showProfile(username string) model {
+	if username == "" {
+		username = m.player.Identity
+	}
+	if username == "" {
+		m.notice = "Guests don't have a profile; see `ssh <host> claim <username>`."
+		return m
+	}
+	gm := GetGameManager()
+	profile, err := buildProfile(gm.Accounts(), gm.Games(), username)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.gameState = "profile"
+	m.profile = profile
+	m.notice = ""
+	return m
+}
+
+// showLeaderboard opens the leaderboard screen
+func (m model) showLeaderboard() model {
+	m.gameState = "leaderboard"
+	m.notice = ""
+	return m
+}
+
+// handleProfileKey leaves the profile screen
+func (m model) handleProfileKey(msg tea.KeyMsg) model {
+	switch msg.String() {
+	case "x", "esc":
+		return m.enterLobby()
+	}
+	return m
+}
+
+// handleLeaderboardKey switches between leaderboards
+func (m model) handleLeaderboardKey(msg tea.KeyMsg) model {
+	pools := ratingPools()
+	switch msg.String() {
+	case "left", "h":
+		m.leaderboardPool = (m.leaderboardPool + len(pools) - 1) % len(pools)
+	case "right", "l":
+		m.leaderboardPool = (m.leaderboardPool + 1) % len(pools)
+	case "x", "esc":
+		return m.enterLobby()
+	}
+	return m
+}
+
+// profileView shows a player's profile
+func (m model) profileView() string {
+	var s strings.Builder
+	s.WriteString("CheSSH profile\n")
+	s.WriteString("X to return to the lobby, Q to quit\n\n")
+	s.WriteString(m.profile.String())
+	return s.String()
+}
+
+// leaderboardView shows the selected leaderboard
+func (m model) leaderboardView() string {
+	var s strings.Builder
+	s.WriteString("CheSSH leaderboards\n")
+	s.WriteString("Left/right to switch between time controls and variants, X to return to the lobby, Q to quit\n\n")
+
+	pools := ratingPools()
+	for i, pool := range pools {
+		if i == m.leaderboardPool {
+			s.WriteString(fmt.Sprintf("[%s] ", poolTitle(pool)))
+		} else {
+			s.WriteString(poolTitle(pool) + " ")
+		}
+	}
+	s.WriteString("\n\n")
+
+	pool := pools[m.leaderboardPool]
+	entries, err := leaderboard(GetGameManager().Accounts(), pool, leaderboardSize)
+	if err != nil {
+		s.WriteString(err.Error() + "\n")
+		return s.String()
+	}
+	s.WriteString(leaderboardText(pool, entries))
+	return s.String()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Puzzle is a position with a forced win. The player makes every other move
+// of the solution, starting with the first; the rest are the replies.
+type Puzzle struct {
+	Title    string
+	FEN      string
+	Solution []string // Moves in coordinate notation, like "d1d8"
+}
+
+var puzzles = []Puzzle{
+	{Title: "Back rank", FEN: "6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1", Solution: []string{"d1d8"}},
+	{Title: "Scholar's mate", FEN: "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", Solution: []string{"h5f7"}},
+	{Title: "Smothered", FEN: "6rk/6pp/8/6N1/8/8/8/7K w - - 0 1", Solution: []string{"g5f7"}},
+	{Title: "Rook and king", FEN: "k7/8/1K6/8/8/8/8/7R w - - 0 1", Solution: []string{"h1h8"}},
+	{Title: "Black's back rank", FEN: "r5k1/5ppp/8/8/8/8/5PPP/6K1 b - - 0 1", Solution: []string{"a8a1"}},
+	{Title: "Philidor's legacy", FEN: "r6k/6pp/7N/8/8/1Q6/8/6K1 w - - 0 1", Solution: []string{"b3g8", "a8g8", "h6f7"}},
+}
+
+// randomPuzzle picks a puzzle to play
+func randomPuzzle() Puzzle {
+	return puzzles[rand.IntN(len(puzzles))]
+}
+
+// parseCoordinateMove parses a move like "e2e4"
+func parseCoordinateMove(s string) (Position, Position, error) {
+	if len(s) != 4 {
+		return Position{}, Position{}, fmt.Errorf("invalid move %q", s)
+	}
+	from := Position{int(s[1] - '1'), int(s[0] - 'a')}
+	to := Position{int(s[3] - '1'), int(s[2] - 'a')}
+	if !from.Valid() || !to.Valid() {
+		return Position{}, Position{}, fmt.Errorf("invalid move %q", s)
+	}
+	return from, to, nil
+}
+
+// puzzleState tracks a player's progress through a puzzle
+type puzzleState struct {
+	puzzle Puzzle
+	step   int // Index of the next solution move
+	color  Color
+	solved bool
+	failed bool
+}
+
+// tryPuzzleMove plays the player's move if it's the solution (or, on the last
+// step, any mate), then plays the reply. It reports whether the move was accepted.
+func (m *model) tryPuzzleMove(from, to Position) bool {
+	p := m.puzzle
+	if p == nil || p.solved || p.failed {
+		return false
+	}
+
+	trial := m.game.Clone()
+	if !trial.MakeMove(from, to) {
+		return false
+	}
+
+	expectedFrom, expectedTo, _ := parseCoordinateMove(p.puzzle.Solution[p.step])
+	lastStep := p.step == len(p.puzzle.Solution)-1
+	mates := trial.IsCheckmate(trial.CurrentTurn)
+	if (from != expectedFrom || to != expectedTo) && !(lastStep && mates) {
+		p.failed = true
+		return true
+	}
+
+	m.game = trial
+	p.step++
+	if p.step >= len(p.puzzle.Solution) {
+		p.solved = true
+		return true
+	}
+
+	replyFrom, replyTo, _ := parseCoordinateMove(p.puzzle.Solution[p.step])
+	m.game.MakeMove(replyFrom, replyTo)
+	p.step++
+	return true
+}
+
+// handlePuzzleKey moves the cursor and plays moves while solving a puzzle
+func (m model) handlePuzzleKey(msg tea.KeyMsg) model {
+	switch msg.String() {
+	case "up", "k", "down", "j", "left", "h", "right", "l":
+		m, _ = m.moveCursor(msg.String())
+	case "f":
+		m.flipped = !m.flipped
+	case "esc":
+		m.selected = nil
+		m.validMoves = make([]Position, 0)
+	case "enter", " ":
+		if m.puzzle.solved || m.puzzle.failed {
+			return m.startPuzzle()
+		}
+		currentPos := Position{m.cursorRow, m.cursorCol}
+		if m.selected == nil {
+			piece := m.game.Board.At(currentPos)
+			if piece.Type != Empty && piece.Color == m.puzzle.color {
+				m.selected = &currentPos
+				m.validMoves = m.getValidMoves(currentPos)
+			}
+		} else if *m.selected == currentPos || m.tryPuzzleMove(*m.selected, currentPos) {
+			m.selected = nil
+			m.validMoves = make([]Position, 0)
+		}
+	default:
+		return m.handleChallengeKey(msg)
+	}
+	return m
+}
+
+// puzzleView describes the puzzle and how the player is doing
+func (m model) puzzleView() string {
+	p := m.puzzle
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("Puzzle: %s\n", p.puzzle.Title))
+	s.WriteString(fmt.Sprintf("%s to play and mate in %d\n", p.color, (len(p.puzzle.Solution)+1)/2))
+	switch {
+	case p.solved:
+		s.WriteString("*** SOLVED ***  Enter for another puzzle\n")
+	case p.failed:
+		s.WriteString(fmt.Sprintf("*** NOT QUITE: the answer was %s ***  Enter for another puzzle\n",
+			strings.Join(p.puzzle.Solution, " ")))
+	default:
+		s.WriteString("SPACE to select, ESC to deselect\n")
+	}
+	s.WriteString("F to flip the board, X to return to the lobby, Q to quit\n\n")
+	return s.String()
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestPuzzleSolutions(t *testing.T) {
+	for _, puzzle := range puzzles {
+		t.Run(puzzle.Title, func(t *testing.T) {
+			g, err := NewGameFromFEN(puzzle.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, move := range puzzle.Solution {
+				from, to, err := parseCoordinateMove(move)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !g.MakeMove(from, to) {
+					t.Fatalf("%s is illegal", move)
+				}
+			}
+			if !g.IsCheckmate(g.CurrentTurn) {
+				t.Errorf("the solution doesn't end in mate")
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Glicko-2 system constants; see http://www.glicko.net/glicko/glicko2.pdf
+const (
+	glickoScale       = 173.7178
+	glickoTau         = 0.5
+	glickoEpsilon     = 0.000001
+	defaultRating     = 1500
+	defaultDeviation  = 350
+	defaultVolatility = 0.06
+	minDeviation      = 45
+
+	// Ratings with a deviation above this are shown as provisional
+	provisionalDeviation = 110
+
+	// How long a rating period lasts when growing the deviation of players
+	// who haven't played for a while
+	ratingPeriod = 24 * time.Hour
+)
+
+// Rating is a player's Glicko-2 rating in one rating pool
+type Rating struct {
+	Rating     float64   `json:"rating"`
+	Deviation  float64   `json:"deviation"`
+	Volatility float64   `json:"volatility"`
+	Games      int       `json:"games"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+func NewRating() Rating {
+	return Rating{
+		Rating:     defaultRating,
+		Deviation:  defaultDeviation,
+		Volatility: defaultVolatility,
+	}
+}
+
+func (r Rating) Provisional() bool {
+	return r.Deviation > provisionalDeviation
+}
+
+func (r Rating) String() string {
+	if r.Provisional() {
+		return fmt.Sprintf("%d?", int(math.Round(r.Rating)))
+	}
+	return fmt.Sprintf("%d", int(math.Round(r.Rating)))
+}
+
+// ratingPool returns the key ratings are tracked under. Standard chess is
+// rated per time control category; each variant has a single rating.
+func ratingPool(settings GameSettings) string {
+	if settings.Variant != Standard {
+		return settings.Variant.String()
+	}
+	return settings.TimeControl.Category()
+}
+
+// Inactive returns the rating with its deviation grown for the rating
+// periods that have passed since it was last played, since we know less
+// about a player's strength the longer they've been away (step 6 of the
+// Glicko-2 paper, for players who didn't compete)
+func (r Rating) Inactive(now time.Time) Rating {
+	if r.LastPlayed.IsZero() || !now.After(r.LastPlayed) {
+		return r
+	}
+	periods := float64(now.Sub(r.LastPlayed)) / float64(ratingPeriod)
+	phi := r.Deviation / glickoScale
+	phi = math.Sqrt(phi*phi + r.Volatility*r.Volatility*periods)
+	r.Deviation = math.Min(phi*glickoScale, defaultDeviation)
+	return r
+}
+
+// Update returns the rating after a single game against opponent, treating
+// each game as its own rating period after growing both deviations for the
+// time since they last played. score is 1 for a win, 0.5 for a draw and 0
+// for a loss.
+func (r Rating) Update(opponent Rating, score float64) Rating {
+	now := time.Now()
+	r, opponent = r.Inactive(now), opponent.Inactive(now)
+
+	mu := (r.Rating - defaultRating) / glickoScale
+	phi := r.Deviation / glickoScale
+	muOpp := (opponent.Rating - defaultRating) / glickoScale
+	phiOpp := opponent.Deviation / glickoScale
+
+	g := 1 / math.Sqrt(1+3*phiOpp*phiOpp/(math.Pi*math.Pi))
+	expected := 1 / (1 + math.Exp(-g*(mu-muOpp)))
+	v := 1 / (g * g * expected * (1 - expected))
+	delta := v * g * (score - expected)
+
+	sigma := newVolatility(phi, r.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-expected)
+
+	updated := Rating{
+		Rating:     newMu*glickoScale + defaultRating,
+		Deviation:  math.Max(newPhi*glickoScale, minDeviation),
+		Volatility: sigma,
+		Games:      r.Games + 1,
+		LastPlayed: now,
+	}
+	updated.Deviation = math.Min(updated.Deviation, defaultDeviation)
+	return updated
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+// (step 5 of the Glicko-2 paper)
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// RatingChange records a player's rating before and after a rated game
+type RatingChange struct {
+	Before Rating
+	After  Rating
+}
+
+func (rc RatingChange) Delta() int {
+	return int(math.Round(rc.After.Rating)) - int(math.Round(rc.Before.Rating))
+}
+
+func (rc RatingChange) String() string {
+	return fmt.Sprintf("%s -> %s (%+d)", rc.Before, rc.After, rc.Delta())
+}
+
+// playerRating returns the identity's current rating in pool. Guests and
+// players new to the pool get the default rating.
+func playerRating(accounts AccountStore, identity, pool string) Rating {
+	if identity == "" {
+		return NewRating()
+	}
+	account, err := accounts.Get(identity)
+	if err != nil {
+		return NewRating()
+	}
+	if rating, ok := account.Ratings[pool]; ok {
+		return rating
+	}
+	return NewRating()
+}
+
+// applyRatings updates and saves both players' ratings for a finished game,
+// returning the changes indexed by color
+func applyRatings(accounts AccountStore, white, black string, pool string, outcome Outcome) ([2]RatingChange, error) {
+	var changes [2]RatingChange
+
+	whiteScore := 0.5
+	switch outcome {
+	case WhiteWins:
+		whiteScore = 1
+	case BlackWins:
+		whiteScore = 0
+	}
+
+	// Each side is rated against the opponent's rating before this game
+	opponents := [2]Rating{playerRating(accounts, black, pool), playerRating(accounts, white, pool)}
+	for _, side := range []struct {
+		color    Color
+		identity string
+		score    float64
+	}{{White, white, whiteScore}, {Black, black, 1 - whiteScore}} {
+		err := accounts.Update(side.identity, func(a *Account) {
+			before, ok := a.Ratings[pool]
+			if !ok {
+				before = NewRating()
+			}
+			changes[side.color] = RatingChange{Before: before, After: before.Update(opponents[side.color], side.score)}
+			if a.Ratings == nil {
+				a.Ratings = make(map[string]Rating)
+			}
+			a.Ratings[pool] = changes[side.color].After
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewVolatility(t *testing.T) {
+	// The worked example in the Glicko-2 paper
+	got := newVolatility(1.1513, 0.06, 1.7785, -0.4834)
+	if math.Abs(got-0.05999) > 0.00001 {
+		t.Errorf("newVolatility = %f, want 0.05999", got)
+	}
+}
+
+func TestRatingUpdate(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		r, opponent   Rating
+		score         float64
+		wantRating    float64
+		wantDeviation float64
+	}{{
+		name:          "new players, win",
+		r:             NewRating(),
+		opponent:      NewRating(),
+		score:         1,
+		wantRating:    1662.31,
+		wantDeviation: 290.32,
+	}, {
+		name:          "new players, loss",
+		r:             NewRating(),
+		opponent:      NewRating(),
+		score:         0,
+		wantRating:    1337.69,
+		wantDeviation: 290.32,
+	}, {
+		name:          "new players, draw",
+		r:             NewRating(),
+		opponent:      NewRating(),
+		score:         0.5,
+		wantRating:    1500,
+		wantDeviation: 290.32,
+	}, {
+		name:          "win against a settled, weaker player",
+		r:             Rating{Rating: 1500, Deviation: 200, Volatility: 0.06},
+		opponent:      Rating{Rating: 1400, Deviation: 30, Volatility: 0.06},
+		score:         1,
+		wantRating:    1563.56,
+		wantDeviation: 175.40,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.r.Update(tt.opponent, tt.score)
+			if math.Abs(got.Rating-tt.wantRating) > 0.01 {
+				t.Errorf("rating = %.2f, want %.2f", got.Rating, tt.wantRating)
+			}
+			if math.Abs(got.Deviation-tt.wantDeviation) > 0.01 {
+				t.Errorf("deviation = %.2f, want %.2f", got.Deviation, tt.wantDeviation)
+			}
+			if got.Games != tt.r.Games+1 {
+				t.Errorf("games = %d, want %d", got.Games, tt.r.Games+1)
+			}
+		})
+	}
+}
+
+func TestRatingInactive(t *testing.T) {
+	now := time.Now()
+	settled := Rating{Rating: 1800, Deviation: minDeviation, Volatility: defaultVolatility}
+
+	for _, tt := range []struct {
+		name       string
+		lastPlayed time.Time
+		want       float64
+	}{
+		{"never played", time.Time{}, minDeviation},
+		{"just played", now, minDeviation},
+		{"a month away", now.Add(-30 * ratingPeriod), 72.69},
+		{"a year away", now.Add(-365 * ratingPeriod), 204.15},
+		{"ten years away", now.Add(-3650 * ratingPeriod), defaultDeviation},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := settled
+			r.LastPlayed = tt.lastPlayed
+			if got := r.Inactive(now).Deviation; math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("deviation = %.2f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRatingUpdateAfterAbsence(t *testing.T) {
+	settled := Rating{Rating: 1800, Deviation: minDeviation, Volatility: defaultVolatility, LastPlayed: time.Now()}
+	returning := settled
+	returning.LastPlayed = time.Now().Add(-365 * ratingPeriod)
+	opponent := Rating{Rating: 1800, Deviation: minDeviation, Volatility: defaultVolatility, LastPlayed: time.Now()}
+
+	active := settled.Update(opponent, 0).Rating
+	away := returning.Update(opponent, 0).Rating
+	if 1800-away <= 4*(1800-active) {
+		t.Errorf("a loss after a year away cost %.1f points, and %.1f for a regular; want it to count for much more", 1800-away, 1800-active)
+	}
+}
+
+func TestRatingWindow(t *testing.T) {
+	if got := ratingWindow(0); got != 100 {
+		t.Errorf("ratingWindow(0) = %v, want 100", got)
+	}
+	if ratingWindow(30*time.Second) <= ratingWindow(10*time.Second) {
+		t.Error("ratingWindow doesn't widen as players wait")
+	}
+}
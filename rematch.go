@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Series is the running score of a run of rematches between two players
+type Series struct {
+	Games  int
+	Scores map[string]float64 // Points by player ID
+}
+
+// withResult returns the series with a finished game added
+func (s Series) withResult(result GameResult, white, black string) Series {
+	next := Series{Games: s.Games + 1, Scores: map[string]float64{white: s.Scores[white], black: s.Scores[black]}}
+	switch result.Outcome {
+	case WhiteWins:
+		next.Scores[white]++
+	case BlackWins:
+		next.Scores[black]++
+	case Draw:
+		next.Scores[white] += 0.5
+		next.Scores[black] += 0.5
+	}
+	return next
+}
+
+// formatScore writes half points as ½, like 2½
+func formatScore(score float64) string {
+	whole, half := math.Modf(score)
+	switch {
+	case half == 0:
+		return fmt.Sprintf("%d", int(whole))
+	case whole == 0:
+		return "½"
+	}
+	return fmt.Sprintf("%d½", int(whole))
+}
+
+// GetSeries returns the score of the games these players have played in a row
+func (gs *GameSession) GetSeries() Series {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.Series
+}
+
+// offerRematch records that the player wants a rematch, and reports
+// whether both players now do
+func (gs *GameSession) offerRematch(playerID string) (bool, error) {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return false, errors.New("only players can ask for a rematch")
+	}
+
+	gs.mu.Lock()
+	if gs.Result == nil {
+		gs.mu.Unlock()
+		return false, errors.New("the game isn't over yet")
+	}
+	if gs.Tournament != "" {
+		gs.mu.Unlock()
+		return false, errors.New("tournament games can't be rematched; the tournament pairs your next game")
+	}
+	opponent := gs.White
+	if player.Color == White {
+		opponent = gs.Black
+	}
+	if !opponent.Connected {
+		gs.mu.Unlock()
+		return false, errors.New("your opponent has left")
+	}
+	gs.rematchOffers[player.Color] = true
+	both := gs.rematchOffers[White] && gs.rematchOffers[Black]
+	gs.mu.Unlock()
+
+	if !both {
+		gs.Broadcast(GameUpdate{Type: "rematch_offered", Data: map[string]interface{}{"color": player.Color}})
+	}
+	return both, nil
+}
+
+// DeclineRematch turns down the opponent's rematch offer
+func (gs *GameSession) DeclineRematch(playerID string) {
+	player := gs.GetPlayer(playerID)
+	if player == nil {
+		return
+	}
+
+	gs.mu.Lock()
+	if !gs.rematchOffers[1-player.Color] {
+		gs.mu.Unlock()
+		return
+	}
+	gs.rematchOffers = [2]bool{}
+	gs.mu.Unlock()
+
+	gs.Broadcast(GameUpdate{Type: "rematch_declined", Data: map[string]interface{}{"color": player.Color}})
+}
+
+// OfferRematch asks for a rematch of the player's finished game. Once both
+// players have asked, a new game starts with the same settings and the
+// colors swapped.
+func (gm *GameManager) OfferRematch(playerID string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	session := gm.activeGames[gm.playerToGame[playerID]]
+	if session == nil {
+		return errors.New("there's no game to rematch")
+	}
+	both, err := session.offerRematch(playerID)
+	if err != nil || !both {
+		return err
+	}
+
+	session.mu.RLock()
+	white, black := session.White, session.Black
+	settings, series := session.settings, session.Series
+	session.mu.RUnlock()
+
+	settings.Rated = settings.Rated && canBeRated(white, black)
+	rematch := gm.startGameLocked(black, white, settings)
+	rematch.mu.Lock()
+	rematch.Series = series
+	rematch.mu.Unlock()
+	return nil
+}
+
+// handleRematchKey offers, accepts or declines a rematch after a game
+func (m model) handleRematchKey(msg tea.KeyMsg) (bool, model) {
+	switch msg.String() {
+	case "a":
+		if err := GetGameManager().OfferRematch(m.player.ID); err != nil {
+			m.notice = err.Error()
+		} else if m.rematch != "received" {
+			m.rematch = "sent"
+			m.notice = ""
+		}
+	case "d":
+		if m.rematch != "received" {
+			return false, m
+		}
+		m.gameSession.DeclineRematch(m.player.ID)
+		m.rematch = ""
+	default:
+		return false, m
+	}
+	return true, m
+}
+
+// seriesLine shows the score when the players have played more than once
+func (m model) seriesLine() string {
+	if m.gameSession == nil || m.player == nil || m.opponent == nil {
+		return ""
+	}
+	series := m.gameSession.GetSeries()
+	if series.Games == 0 || (series.Games == 1 && m.result != nil) {
+		return ""
+	}
+	return fmt.Sprintf("Series: %s %s–%s %s\n", m.player.Name, formatScore(series.Scores[m.player.ID]),
+		formatScore(series.Scores[m.opponent.ID]), m.opponent.Name)
+}
+
+// rematchLines describes any rematch offer on the game-over screen
+func (m model) rematchLines() string {
+	switch m.rematch {
+	case "sent":
+		return "Rematch offered; waiting for your opponent.\n"
+	case "received":
+		return ">>> Your opponent wants a rematch. A to accept, D to decline <<<\n"
+	}
+	if m.gameSession != nil && m.gameSession.Tournament != "" {
+		return ""
+	}
+	return "Press A to offer a rematch.\n"
+}
@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestRematchSeries(t *testing.T) {
+	gm := newGameManager()
+	game, a, b := startTestGame(t, gm, GameSettings{TimeControl: DefaultTimeControl})
+	if err := gm.OfferRematch(a.ID); err == nil {
+		t.Fatal("offered a rematch of a game that isn't over")
+	}
+
+	// Each game ends, one player asks for a rematch and the other takes it up
+	for i, tt := range []struct {
+		end        func(gs *GameSession, white, black *Player) error
+		wantScores [2]float64 // a's and b's
+	}{
+		{func(gs *GameSession, white, black *Player) error { return gs.Resign(black.ID) }, [2]float64{1, 0}},
+		{func(gs *GameSession, white, black *Player) error {
+			if err := gs.OfferDraw(white.ID); err != nil {
+				return err
+			}
+			return gs.AcceptDraw(black.ID)
+		}, [2]float64{1.5, 0.5}},
+		{func(gs *GameSession, white, black *Player) error { return gs.Resign(black.ID) }, [2]float64{2.5, 0.5}},
+	} {
+		wantWhite, wantBlack := a, b
+		if i%2 == 1 {
+			wantWhite, wantBlack = b, a
+		}
+		if game.White.ID != wantWhite.ID || game.Black.ID != wantBlack.ID {
+			t.Fatalf("game %d: %s has White and %s Black, want colors swapped each game", i+1, game.White.Name, game.Black.Name)
+		}
+		if err := tt.end(game, game.White, game.Black); err != nil {
+			t.Fatal(err)
+		}
+		series := game.GetSeries()
+		if series.Games != i+1 || series.Scores[a.ID] != tt.wantScores[0] || series.Scores[b.ID] != tt.wantScores[1] {
+			t.Errorf("game %d: series is %d games, %v–%v; want %d games, %v–%v", i+1,
+				series.Games, series.Scores[a.ID], series.Scores[b.ID], i+1, tt.wantScores[0], tt.wantScores[1])
+		}
+
+		if err := gm.OfferRematch(b.ID); err != nil {
+			t.Fatal(err)
+		}
+		if gm.GetGameSession(a.ID) != game {
+			t.Fatal("a rematch started before both players asked")
+		}
+		if err := gm.OfferRematch(a.ID); err != nil {
+			t.Fatal(err)
+		}
+		next := gm.GetGameSession(a.ID)
+		if next == game || next != gm.GetGameSession(b.ID) {
+			t.Fatal("the rematch didn't start")
+		}
+		game = next
+	}
+}
+
+func TestDeclineRematch(t *testing.T) {
+	gm := newGameManager()
+	game, white, black := startTestGame(t, gm, GameSettings{TimeControl: DefaultTimeControl})
+	if err := game.Resign(white.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.OfferRematch(white.ID); err != nil {
+		t.Fatal(err)
+	}
+	game.DeclineRematch(black.ID)
+	if err := gm.OfferRematch(black.ID); err != nil {
+		t.Fatal(err)
+	}
+	if gm.GetGameSession(white.ID) != game {
+		t.Error("a declined rematch started once the other player asked")
+	}
+}
+
+func TestFormatScore(t *testing.T) {
+	for score, want := range map[float64]string{0: "0", 0.5: "½", 1: "1", 2.5: "2½", 10: "10"} {
+		if got := formatScore(score); got != want {
+			t.Errorf("formatScore(%v) = %q, want %q", score, got, want)
+		}
+	}
+}
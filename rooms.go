@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// roomCodeAlphabet leaves out characters that are easy to confuse, like 0/O and 1/I
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const roomCodeLength = 4
+
+// Room is a private game that only players with the join code can enter
+type Room struct {
+	Code     string
+	Creator  *Player
+	Settings GameSettings
+	Color    string // The creator's color preference
+	GameID   string // Set once someone has joined
+	Created  time.Time
+}
+
+// ParseRoomArgs parses room options like "black 5+3 threecheck fen <FEN>".
+// Everything after "fen" is taken as the starting position.
+func ParseRoomArgs(args []string) (GameSettings, string, error) {
+	settings := GameSettings{TimeControl: DefaultTimeControl, Private: true}
+	color := ColorRandom
+
+	for i, arg := range args {
+		arg = strings.ToLower(arg)
+		switch arg {
+		case ColorWhite, ColorBlack, ColorRandom:
+			color = arg
+		case "rated":
+			settings.Rated = true
+		case "casual":
+			settings.Rated = false
+		case "fen":
+			settings.FEN = strings.Join(args[i+1:], " ")
+			if _, err := NewGameFromFEN(settings.FEN); err != nil {
+				return settings, color, err
+			}
+			// Custom positions can't be compared fairly, so they're never rated
+			settings.Rated = false
+			return settings, color, nil
+		default:
+			if isTimeControl(arg) {
+				tc, err := ParseTimeControl(arg)
+				if err != nil {
+					return settings, color, err
+				}
+				settings.TimeControl = tc
+				continue
+			}
+			variant, err := ParseVariant(arg)
+			if err != nil {
+				return settings, color, fmt.Errorf("unknown room option %q", arg)
+			}
+			settings.Variant = variant
+		}
+	}
+	return settings, color, nil
+}
+
+func newRoomCode() string {
+	code := make([]byte, roomCodeLength)
+	_, _ = rand.Read(code)
+	for i := range code {
+		// The alphabet has 32 characters, so this doesn't bias any of them
+		code[i] = roomCodeAlphabet[int(code[i])%len(roomCodeAlphabet)]
+	}
+	return string(code)
+}
+
+// CreateRoom opens a private room. The creator leaves the public queue and
+// waits for someone to join with the returned room's code.
+func (gm *GameManager) CreateRoom(creator *Player, settings GameSettings, color string) (*Room, error) {
+	settings.Private = true
+	if err := checkCorrespondence(settings, creator); err != nil {
+		return nil, err
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.inGameLocked(creator.ID) {
+		return nil, errors.New("finish your current game before opening a room")
+	}
+	gm.removeFromQueueLocked(creator.ID)
+	gm.closeRoomsLocked(creator.ID)
+
+	code := newRoomCode()
+	for gm.rooms[code] != nil {
+		code = newRoomCode()
+	}
+
+	room := &Room{
+		Code:     code,
+		Creator:  creator,
+		Settings: settings,
+		Color:    color,
+		Created:  time.Now(),
+	}
+	gm.rooms[code] = room
+	return room, nil
+}
+
+// JoinRoom starts the room's game with the joining player as the opponent
+func (gm *GameManager) JoinRoom(code string, player *Player) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	room, exists := gm.rooms[code]
+	if !exists {
+		return fmt.Errorf("no room with code %s", code)
+	}
+	if room.GameID != "" {
+		return fmt.Errorf("room %s already has two players", code)
+	}
+	if room.Creator.ID == player.ID {
+		return errors.New("you can't join your own room")
+	}
+	if gm.inGameLocked(player.ID) {
+		return errors.New("finish your current game before joining a room")
+	}
+
+	settings := room.Settings
+	if err := checkCorrespondence(settings, player); err != nil {
+		return err
+	}
+	settings.Rated = settings.Rated && canBeRated(room.Creator, player)
+
+	// Take the room out while the game starts so it isn't closed as
+	// a waiting room, then keep its code until the game is gone
+	delete(gm.rooms, code)
+	white, black := gm.assignColorsLocked(room.Creator, player, room.Color)
+	session := gm.startGameLocked(white, black, settings)
+	room.GameID = session.ID
+	gm.rooms[code] = room
+	return nil
+}
+
+// CloseRoom closes the player's room if nobody has joined it yet
+func (gm *GameManager) CloseRoom(playerID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.closeRoomsLocked(playerID)
+}
+
+// closeRoomsLocked closes rooms the player created that are still waiting
+// for an opponent. Callers must hold gm.mu.
+func (gm *GameManager) closeRoomsLocked(playerID string) {
+	for code, room := range gm.rooms {
+		if room.Creator.ID == playerID && room.GameID == "" {
+			delete(gm.rooms, code)
+		}
+	}
+}
+
+// expireRoomCodesLocked frees the codes of rooms whose game is gone.
+// Callers must hold gm.mu.
+func (gm *GameManager) expireRoomCodesLocked(gameID string) {
+	for code, room := range gm.rooms {
+		if room.GameID == gameID {
+			delete(gm.rooms, code)
+		}
+	}
+}
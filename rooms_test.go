@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoomCodes(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 500)
+
+	seen := map[string]bool{}
+	for _, p := range players {
+		room, err := gm.CreateRoom(p, GameSettings{TimeControl: DefaultTimeControl}, ColorRandom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(room.Code) != roomCodeLength {
+			t.Errorf("room code %q has %d characters, want %d", room.Code, len(room.Code), roomCodeLength)
+		}
+		for _, c := range room.Code {
+			if !strings.ContainsRune(roomCodeAlphabet, c) {
+				t.Errorf("room code %q has %q, which isn't in the alphabet", room.Code, c)
+			}
+		}
+		if seen[room.Code] {
+			t.Errorf("room code %q was handed out twice", room.Code)
+		}
+		seen[room.Code] = true
+	}
+	if len(gm.rooms) != len(players) {
+		t.Errorf("%d rooms are open, want %d", len(gm.rooms), len(players))
+	}
+}
+
+func TestJoinRoom(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 3)
+	alice, bob, carol := players[0], players[1], players[2]
+
+	room, err := gm.CreateRoom(alice, GameSettings{TimeControl: DefaultTimeControl}, ColorWhite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gm.JoinRoom("ZZZZ", bob); err == nil || !strings.Contains(err.Error(), "no room with code") {
+		t.Errorf("joining an unknown room: got %v", err)
+	}
+	if err := gm.JoinRoom(room.Code, alice); err == nil || !strings.Contains(err.Error(), "your own room") {
+		t.Errorf("joining your own room: got %v", err)
+	}
+
+	// Codes are typed by hand, so case and stray spaces don't matter
+	if err := gm.JoinRoom(" "+strings.ToLower(room.Code)+" ", bob); err != nil {
+		t.Fatalf("joining with a lowercase code: %v", err)
+	}
+	session := gm.GetGameSession(bob.ID)
+	if session == nil {
+		t.Fatal("bob isn't in a game after joining the room")
+	}
+	if session.White != alice || session.Black != bob {
+		t.Errorf("alice asked for white, but the game is %s vs %s", session.White.Name, session.Black.Name)
+	}
+	if room.GameID != session.ID {
+		t.Errorf("room game is %q, want %q", room.GameID, session.ID)
+	}
+
+	if err := gm.JoinRoom(room.Code, carol); err == nil || !strings.Contains(err.Error(), "already has two players") {
+		t.Errorf("joining a full room: got %v", err)
+	}
+	if _, err := gm.CreateRoom(alice, GameSettings{TimeControl: DefaultTimeControl}, ColorRandom); err == nil {
+		t.Error("alice opened a room in the middle of a game")
+	}
+}
+
+func TestRoomLifecycle(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 2)
+	alice, bob := players[0], players[1]
+
+	// Closing a room before anyone joins frees its code
+	room, err := gm.CreateRoom(alice, GameSettings{TimeControl: DefaultTimeControl}, ColorRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.CloseRoom(alice.ID)
+	if gm.rooms[room.Code] != nil {
+		t.Fatalf("room %s is still open after alice closed it", room.Code)
+	}
+	if err := gm.JoinRoom(room.Code, bob); err == nil {
+		t.Fatal("bob joined a closed room")
+	}
+
+	// Opening a second room replaces the first
+	first, err := gm.CreateRoom(alice, GameSettings{TimeControl: DefaultTimeControl}, ColorRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err = gm.CreateRoom(alice, GameSettings{TimeControl: DefaultTimeControl}, ColorRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Code != room.Code && gm.rooms[first.Code] != nil {
+		t.Errorf("alice's first room %s is still open after opening %s", first.Code, room.Code)
+	}
+
+	// Once the game starts, the code is kept until the game is gone
+	if err := gm.JoinRoom(room.Code, bob); err != nil {
+		t.Fatal(err)
+	}
+	gm.CloseRoom(alice.ID)
+	if gm.rooms[room.Code] == nil {
+		t.Fatalf("room %s was closed while its game was being played", room.Code)
+	}
+
+	session := gm.GetGameSession(alice.ID)
+	if err := session.Resign(alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	gm.LeaveGame(alice.ID)
+	if gm.rooms[room.Code] == nil {
+		t.Fatalf("room %s expired while bob was still at the board", room.Code)
+	}
+	gm.LeaveGame(bob.ID)
+	if gm.rooms[room.Code] != nil {
+		t.Errorf("room %s is still open after both players left", room.Code)
+	}
+}
+
+func TestRoomsStayOutOfQueue(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 2)
+	alice, bob := players[0], players[1]
+	settings := GameSettings{TimeControl: DefaultTimeControl}
+
+	gm.mu.Lock()
+	gm.playerQueue = append(gm.playerQueue, &queueEntry{player: alice, settings: settings})
+	gm.mu.Unlock()
+
+	room, err := gm.CreateRoom(alice, settings, ColorRandom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob seeks the same kind of game, but alice is waiting in her room
+	gm.AddPlayer(bob, settings)
+	if session := gm.GetGameSession(bob.ID); session != nil {
+		t.Fatalf("bob was matched with %s vs %s while alice waited in room %s", session.White.Name, session.Black.Name, room.Code)
+	}
+	gm.mu.Lock()
+	for _, entry := range gm.playerQueue {
+		if entry.player == alice {
+			t.Error("alice is still in the queue after opening a room")
+		}
+	}
+	gm.mu.Unlock()
+	if room.GameID != "" {
+		t.Errorf("room %s has a game before anyone joined", room.Code)
+	}
+}
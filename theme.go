@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// squareKind is what a square on the board is showing
+type squareKind int
+
+const (
+	lightSquare squareKind = iota
+	darkSquare
+	cursorSquare        // Our cursor
+	moverCursorSquare   // The other side's cursor
+	selectedSquare      // The piece we picked up
+	validMoveSquare     // Where it can go
+	moverSelectedSquare // The piece the other side picked up
+	moverMoveSquare     // Where that one can go
+	checkSquare         // A king in check
+	lastMoveSquare      // Where the last move came from or went to
+	premoveSquare       // Where a queued premove comes from or goes to
+)
+
+// squareColors are a square's background and the color of the piece on it
+type squareColors struct {
+	bg, fg lipgloss.TerminalColor
+}
+
+// Theme is a named color scheme for the board
+type Theme struct {
+	Name        string
+	Description string
+	squares     map[squareKind]squareColors // Nil for a theme made of text attributes alone
+}
+
+// color describes a color for every color profile, so themes look right on
+// 16 and 256 color terminals as well as truecolor ones
+func color(trueColor, ansi256, ansi string) lipgloss.CompleteColor {
+	return lipgloss.CompleteColor{TrueColor: trueColor, ANSI256: ansi256, ANSI: ansi}
+}
+
+var (
+	black = color("#000000", "16", "0")
+	white = color("#ffffff", "231", "15")
+)
+
+// themes lists the board themes, the default first
+var themes = []Theme{{
+	Name:        "classic",
+	Description: "grey and black squares",
+	squares: map[squareKind]squareColors{
+		lightSquare:         {color("#808080", "244", "8"), lipgloss.NoColor{}},
+		darkSquare:          {color("#000000", "16", "0"), lipgloss.NoColor{}},
+		cursorSquare:        {color("#cd0000", "160", "1"), lipgloss.NoColor{}},
+		moverCursorSquare:   {color("#0000ee", "20", "4"), lipgloss.NoColor{}},
+		selectedSquare:      {color("#cdcd00", "178", "3"), lipgloss.NoColor{}},
+		validMoveSquare:     {color("#00cd00", "34", "2"), lipgloss.NoColor{}},
+		moverSelectedSquare: {color("#cd00cd", "127", "5"), lipgloss.NoColor{}},
+		moverMoveSquare:     {color("#00cdcd", "37", "6"), lipgloss.NoColor{}},
+		checkSquare:         {color("#ff0000", "196", "9"), lipgloss.NoColor{}},
+		lastMoveSquare:      {color("#5f5f00", "58", "11"), lipgloss.NoColor{}},
+		premoveSquare:       {color("#5f5faf", "61", "12"), lipgloss.NoColor{}},
+	},
+}, {
+	Name:        "high-contrast",
+	Description: "white and black squares, bright highlights",
+	squares: map[squareKind]squareColors{
+		lightSquare:         {white, black},
+		darkSquare:          {black, white},
+		cursorSquare:        {color("#ff0000", "196", "9"), black},
+		moverCursorSquare:   {color("#0000ff", "21", "12"), white},
+		selectedSquare:      {color("#ffff00", "226", "11"), black},
+		validMoveSquare:     {color("#00ff00", "46", "10"), black},
+		moverSelectedSquare: {color("#ff00ff", "201", "13"), black},
+		moverMoveSquare:     {color("#00ffff", "51", "14"), black},
+		checkSquare:         {color("#ff8700", "208", "1"), black},
+		lastMoveSquare:      {color("#8a8a8a", "245", "8"), black},
+		premoveSquare:       {color("#8700ff", "93", "5"), white},
+	},
+}, {
+	Name:        "colorblind",
+	Description: "highlights that don't rely on telling red from green",
+	squares: map[squareKind]squareColors{
+		lightSquare:         {color("#d9d9d9", "253", "7"), black},
+		darkSquare:          {color("#767676", "243", "8"), black},
+		cursorSquare:        {color("#e69f00", "214", "3"), black},
+		moverCursorSquare:   {color("#0072b2", "25", "4"), white},
+		selectedSquare:      {color("#f0e442", "227", "11"), black},
+		validMoveSquare:     {color("#56b4e9", "74", "14"), black},
+		moverSelectedSquare: {color("#cc79a7", "175", "5"), black},
+		moverMoveSquare:     {color("#009e73", "36", "6"), white},
+		checkSquare:         {color("#d55e00", "166", "1"), white},
+		lastMoveSquare:      {color("#a89f91", "138", "13"), black},
+		premoveSquare:       {color("#332288", "17", "4"), white},
+	},
+}, {
+	Name:        "monochrome",
+	Description: "no colors, just reverse video, bold and underline",
+}}
+
+// parseTheme finds a theme by name
+func parseTheme(name string) (Theme, error) {
+	for _, theme := range themes {
+		if theme.Name == strings.ToLower(name) {
+			return theme, nil
+		}
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q, want one of %s", name, strings.Join(themeNames(), ", "))
+}
+
+// themeNames lists the themes' names
+func themeNames() []string {
+	var names []string
+	for _, theme := range themes {
+		names = append(names, theme.Name)
+	}
+	return names
+}
+
+// style is how the theme draws a square. light says whether the square
+// underneath any highlight is a light one.
+func (t Theme) style(r *lipgloss.Renderer, kind squareKind, light bool) lipgloss.Style {
+	style := r.NewStyle()
+	if t.squares != nil {
+		colors := t.squares[kind]
+		return style.Background(colors.bg).Foreground(colors.fg)
+	}
+
+	// Light squares are drawn in reverse video, and highlights add
+	// attributes to the square or swap it
+	style = style.Reverse(light)
+	switch kind {
+	case cursorSquare:
+		return style.Underline(true).Bold(true)
+	case moverCursorSquare:
+		return style.Underline(true)
+	case selectedSquare:
+		return style.Reverse(!light).Bold(true)
+	case validMoveSquare, moverMoveSquare:
+		return style.Reverse(!light)
+	case moverSelectedSquare:
+		return style.Reverse(!light).Underline(true)
+	case checkSquare:
+		return style.Bold(true).Blink(true)
+	case lastMoveSquare:
+		return style.Bold(true)
+	case premoveSquare:
+		return style.Reverse(!light).Italic(true)
+	}
+	return style
+}
+
+// squareMarks bracket a square's contents where the terminal can't show
+// any styling at all, so the cursor and selection can still be seen
+var squareMarks = map[squareKind][2]string{
+	cursorSquare:    {"[", "]"},
+	selectedSquare:  {"(", ")"},
+	validMoveSquare: {"*", "*"},
+	premoveSquare:   {"{", "}"},
+}
+
+// styled reports whether the renderer can show colors or text attributes
+func styled(r *lipgloss.Renderer) bool {
+	return r.ColorProfile() != termenv.Ascii
+}
+
+// PieceSet is a named way of drawing the pieces
+type PieceSet struct {
+	Name        string
+	Description string
+	glyph       func(Piece) string
+}
+
+// pieceSets lists the piece sets, the default first
+var pieceSets = []PieceSet{{
+	Name:        "unicode",
+	Description: "chess symbols, outlined for White and filled for Black",
+	glyph:       Piece.String,
+}, {
+	Name:        "letters",
+	Description: "KQRBNP for White and kqrbnp for Black, for fonts without chess symbols",
+	glyph: func(p Piece) string {
+		letter := "P"
+		if p.Type != Pawn {
+			letter = pieceLetters[p.Type]
+		}
+		if p.Color == Black {
+			return strings.ToLower(letter)
+		}
+		return letter
+	},
+}}
+
+// parsePieceSet finds a piece set by name
+func parsePieceSet(name string) (PieceSet, error) {
+	for _, set := range pieceSets {
+		if set.Name == strings.ToLower(name) {
+			return set, nil
+		}
+	}
+	return PieceSet{}, fmt.Errorf("unknown piece set %q, want one of %s", name, strings.Join(pieceSetNames(), ", "))
+}
+
+// theme is the player's board theme
+func (m model) theme() Theme {
+	if theme, err := parseTheme(m.prefs.Theme); err == nil {
+		return theme
+	}
+	return themes[0]
+}
+
+// pieceSet is the player's piece set
+func (m model) pieceSet() PieceSet {
+	if set, err := parsePieceSet(m.prefs.Pieces); err == nil {
+		return set
+	}
+	return pieceSets[0]
+}
+
+// glyph draws a piece in the player's piece set
+func (m model) glyph(p Piece) string {
+	if p.Type == Empty {
+		return " "
+	}
+	return m.pieceSet().glyph(p)
+}
+
+// lipglossRenderer is the session's renderer, which knows how many colors
+// the player's terminal can show
+func (m model) lipglossRenderer() *lipgloss.Renderer {
+	if m.renderer == nil {
+		return lipgloss.DefaultRenderer()
+	}
+	return m.renderer
+}
+
+// setStyle changes the player's theme and piece set from a line like
+// "colorblind letters", remembering them for players with an account
+func (m model) setStyle(input string) model {
+	for _, word := range strings.Fields(input) {
+		if theme, err := parseTheme(word); err == nil {
+			m.prefs.Theme = theme.Name
+		} else if set, err := parsePieceSet(word); err == nil {
+			m.prefs.Pieces = set.Name
+		} else {
+			m.notice = fmt.Sprintf("Unknown theme or piece set %q; themes are %s and piece sets are %s.",
+				word, strings.Join(themeNames(), ", "), strings.Join(pieceSetNames(), ", "))
+			return m
+		}
+	}
+	m.notice = fmt.Sprintf("Board theme %s with %s pieces.", m.theme().Name, m.pieceSet().Name)
+	if err := savePreferences(GetGameManager().Accounts(), m.player.Identity, m.prefs); err != nil {
+		m.notice = fmt.Sprintf("Failed to save your preferences: %v", err)
+	}
+	return m
+}
+
+// pieceSetNames lists the piece sets' names
+func pieceSetNames() []string {
+	var names []string
+	for _, set := range pieceSets {
+		names = append(names, set.Name)
+	}
+	return names
+}
@@ -0,0 +1,937 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tournament formats
+const (
+	FormatSwiss      = "swiss"
+	FormatRoundRobin = "roundrobin"
+	FormatArena      = "arena"
+)
+
+const (
+	// defaultArenaLength is how long an arena runs when no length is given
+	defaultArenaLength = 30 * time.Minute
+	// arenaRest gives arena players a moment to see their result before the next game
+	arenaRest = 5 * time.Second
+	// finishedTournamentTTL is how long final standings stay in the lobby
+	finishedTournamentTTL = time.Hour
+	// swissPairingBudget bounds the search for pairings without rematches
+	swissPairingBudget = 100000
+)
+
+// Tournament is a competition whose games the server pairs and starts by itself
+type Tournament struct {
+	ID       string
+	Name     string
+	Format   string
+	Settings GameSettings
+	Creator  *Player
+	Rounds   int           // Swiss and round-robin; a Swiss without one picks it at the start
+	Length   time.Duration // Arena
+	Entrants []*Entrant
+	Round    int // Current round, from 1; 0 until the tournament starts
+	Games    []*TournamentGame
+	Started  time.Time
+	Ends     time.Time // When an arena stops pairing
+	Finished time.Time
+	schedule [][][2]int // Round-robin pairings by round, as indexes into Entrants; -1 is a bye
+}
+
+// Entrant is a player's place in a tournament
+type Entrant struct {
+	Player    *Player
+	Withdrawn bool
+	colors    [2]int    // Games played as each color
+	rested    time.Time // When the entrant's last arena game ended
+	last      *Entrant  // Most recent opponent, so arenas don't repeat pairings back to back
+}
+
+// TournamentGame is one pairing. Byes have no Black, and forfeits no Session.
+type TournamentGame struct {
+	Round   int
+	White   *Entrant
+	Black   *Entrant
+	Bye     bool
+	Session *GameSession
+	Result  *GameResult
+}
+
+// done reports whether the pairing needs nothing more from its players
+func (g *TournamentGame) done() bool {
+	return g.Bye || g.Result != nil
+}
+
+// Standing is an entrant's line in the tournament table
+type Standing struct {
+	PlayerID        string
+	Name            string
+	Score           float64
+	Buchholz        float64 // Sum of the opponents' scores
+	SonnebornBerger float64 // Sum of the scores of beaten opponents, plus half those drawn with
+	Played          int
+	Withdrawn       bool
+	Playing         bool
+}
+
+// PairingInfo describes a tournament game for display
+type PairingInfo struct {
+	White  string
+	Black  string
+	Result string // A score like "1-0", or "playing", "bye" or "forfeit"
+}
+
+// TournamentInfo is a snapshot of a tournament for the lobby and standings screen
+type TournamentInfo struct {
+	ID        string
+	Name      string
+	Format    string
+	Settings  GameSettings
+	CreatorID string
+	Status    string
+	Started   bool
+	Finished  bool
+	Standings []Standing
+	Pairings  []PairingInfo // The current round, or an arena's games in progress
+}
+
+// formatTitle names a tournament format for display
+func formatTitle(format string) string {
+	switch format {
+	case FormatSwiss:
+		return "Swiss"
+	case FormatRoundRobin:
+		return "Round Robin"
+	case FormatArena:
+		return "Arena"
+	}
+	return format
+}
+
+// ParseTournamentArgs parses tournament options like "swiss 5 3+2" or
+// "arena 45 threecheck". The format comes first; for a Swiss the number is
+// how many rounds, and for an arena how many minutes it runs.
+func ParseTournamentArgs(args []string) (string, int, time.Duration, GameSettings, error) {
+	settings := GameSettings{TimeControl: DefaultTimeControl, Rated: true}
+	if len(args) == 0 {
+		return "", 0, 0, settings, errors.New("usage: tournament swiss [rounds] | roundrobin | arena [minutes], then [minutes+increment] [variant] [rated|casual]")
+	}
+
+	format := strings.ToLower(args[0])
+	switch format {
+	case "rr", "round-robin":
+		format = FormatRoundRobin
+	case FormatSwiss, FormatRoundRobin, FormatArena:
+	default:
+		return "", 0, 0, settings, fmt.Errorf("unknown tournament format %q, want swiss, roundrobin or arena", args[0])
+	}
+
+	rounds, length := 0, time.Duration(0)
+	for _, arg := range args[1:] {
+		arg = strings.ToLower(arg)
+		if n, err := strconv.Atoi(arg); err == nil {
+			switch {
+			case n < 1 || n > 100:
+				return "", 0, 0, settings, fmt.Errorf("%d is out of range", n)
+			case format == FormatSwiss:
+				rounds = n
+			case format == FormatArena:
+				length = time.Duration(n) * time.Minute
+			default:
+				return "", 0, 0, settings, errors.New("round-robin tournaments have one round per opponent")
+			}
+			continue
+		}
+		switch arg {
+		case "rated":
+			settings.Rated = true
+		case "casual":
+			settings.Rated = false
+		default:
+			if isTimeControl(arg) {
+				tc, err := ParseTimeControl(arg)
+				if err != nil {
+					return "", 0, 0, settings, err
+				}
+				settings.TimeControl = tc
+				continue
+			}
+			variant, err := ParseVariant(arg)
+			if err != nil {
+				return "", 0, 0, settings, fmt.Errorf("unknown tournament option %q", arg)
+			}
+			settings.Variant = variant
+		}
+	}
+	if settings.TimeControl.Correspondence() {
+		return "", 0, 0, settings, errors.New("tournaments are played live; pick a time control like 5+3")
+	}
+	if format == FormatArena && length == 0 {
+		length = defaultArenaLength
+	}
+	return format, rounds, length, settings, nil
+}
+
+// CreateTournament opens a tournament for players to join, with its creator as the first entrant
+func (gm *GameManager) CreateTournament(creator *Player, format string, rounds int, length time.Duration, settings GameSettings) (*Tournament, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	for _, t := range gm.tournaments {
+		if t.Creator.ID == creator.ID && t.Finished.IsZero() {
+			return nil, fmt.Errorf("you're already running %s", t.Name)
+		}
+	}
+
+	t := &Tournament{
+		ID:       fmt.Sprintf("tournament_%d", time.Now().UnixNano()),
+		Name:     fmt.Sprintf("%s's %s", creator.Name, formatTitle(format)),
+		Format:   format,
+		Settings: settings,
+		Creator:  creator,
+		Rounds:   rounds,
+		Length:   length,
+		Entrants: []*Entrant{{Player: creator}},
+	}
+	gm.tournaments[t.ID] = t
+	return t, nil
+}
+
+// JoinTournament enters the player in a tournament. Swiss tournaments and
+// arenas may be joined late; withdrawn players may come back to them.
+func (gm *GameManager) JoinTournament(id string, player *Player) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	t, exists := gm.tournaments[id]
+	if !exists || !t.Finished.IsZero() {
+		return errors.New("that tournament is over")
+	}
+	if !t.Started.IsZero() && t.Format == FormatRoundRobin {
+		return errors.New("round-robin tournaments can't be joined once they've started")
+	}
+	if e := t.entrant(player.ID); e != nil {
+		if !e.Withdrawn {
+			return errors.New("you're already in this tournament")
+		}
+		e.Withdrawn = false
+		return nil
+	}
+	t.Entrants = append(t.Entrants, &Entrant{Player: player})
+	return nil
+}
+
+// WithdrawFromTournament takes the player out of future pairings. Leaving
+// before the start removes them from the tournament, and cancels it if they
+// created it.
+func (gm *GameManager) WithdrawFromTournament(id, playerID string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	t, exists := gm.tournaments[id]
+	if !exists || !t.Finished.IsZero() {
+		return errors.New("that tournament is over")
+	}
+	if t.entrant(playerID) == nil {
+		return errors.New("you're not in this tournament")
+	}
+	gm.withdrawLocked(t, playerID)
+	return nil
+}
+
+// withdrawLocked takes the player out of the tournament. Callers must hold gm.mu.
+func (gm *GameManager) withdrawLocked(t *Tournament, playerID string) {
+	if t.Started.IsZero() {
+		if t.Creator.ID == playerID {
+			delete(gm.tournaments, t.ID)
+			return
+		}
+		for i, e := range t.Entrants {
+			if e.Player.ID == playerID {
+				t.Entrants = append(t.Entrants[:i], t.Entrants[i+1:]...)
+				return
+			}
+		}
+		return
+	}
+	if e := t.entrant(playerID); e != nil {
+		e.Withdrawn = true
+	}
+}
+
+// StartTournament begins pairing. Only the creator can start it.
+func (gm *GameManager) StartTournament(id, playerID string) error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	t, exists := gm.tournaments[id]
+	if !exists {
+		return errors.New("that tournament is over")
+	}
+	if t.Creator.ID != playerID {
+		return errors.New("only the organizer can start the tournament")
+	}
+	if !t.Started.IsZero() {
+		return errors.New("the tournament has already started")
+	}
+	if len(t.Entrants) < 2 {
+		return errors.New("wait for at least one more player to join")
+	}
+
+	now := time.Now()
+	t.Started = now
+	switch t.Format {
+	case FormatSwiss:
+		if t.Rounds == 0 {
+			// Enough rounds to find a clear winner in most fields
+			t.Rounds = int(math.Ceil(math.Log2(float64(len(t.Entrants))))) + 1
+		}
+	case FormatRoundRobin:
+		t.schedule = roundRobinSchedule(len(t.Entrants))
+		t.Rounds = len(t.schedule)
+	case FormatArena:
+		t.Ends = now.Add(t.Length)
+	}
+	gm.runTournamentLocked(t, now)
+	return nil
+}
+
+// runTournamentsLocked collects results and starts the games that are due
+// in every running tournament. Callers must hold gm.mu.
+func (gm *GameManager) runTournamentsLocked(now time.Time) {
+	for id, t := range gm.tournaments {
+		if !t.Finished.IsZero() {
+			if now.Sub(t.Finished) > finishedTournamentTTL {
+				delete(gm.tournaments, id)
+			}
+			continue
+		}
+		if !t.Started.IsZero() {
+			gm.runTournamentLocked(t, now)
+		}
+	}
+}
+
+// runTournamentLocked moves a running tournament along. Callers must hold gm.mu.
+func (gm *GameManager) runTournamentLocked(t *Tournament, now time.Time) {
+	roundDone := true
+	for _, g := range t.Games {
+		if g.Result == nil && g.Session != nil {
+			if result, _ := g.Session.GetResult(); result != nil {
+				g.Result = result
+				g.White.rested, g.Black.rested = now, now
+			}
+		}
+		if g.Round == t.Round && !g.done() {
+			roundDone = false
+		}
+	}
+
+	if t.Format == FormatArena {
+		if now.Before(t.Ends) {
+			gm.pairArenaLocked(t, now)
+		} else if roundDone {
+			gm.finishTournamentLocked(t, now)
+		}
+		return
+	}
+
+	if !roundDone {
+		return
+	}
+	if t.Round == t.Rounds || t.remaining() < 2 {
+		gm.finishTournamentLocked(t, now)
+		return
+	}
+	if gm.availableEntrantsLocked(t) < 2 {
+		// Wait for players to come back or finish their other games, rather
+		// than pairing a round nobody plays
+		return
+	}
+	t.Round++
+	if t.Format == FormatSwiss {
+		gm.pairSwissLocked(t)
+	} else {
+		gm.pairRoundRobinLocked(t)
+	}
+}
+
+// finishTournamentLocked ends the tournament and tells the entrants who
+// won. Callers must hold gm.mu.
+func (gm *GameManager) finishTournamentLocked(t *Tournament, now time.Time) {
+	t.Finished = now
+	winner := t.standings()[0].Name
+	for _, e := range t.Entrants {
+		notifyPlayer(e.Player, GameUpdate{
+			Type: "tournament_over",
+			Data: map[string]interface{}{"name": t.Name, "winner": winner},
+		})
+	}
+}
+
+// availableLocked reports whether the entrant can start a tournament game
+// now: online, still in the tournament and not busy in another game.
+// Callers must hold gm.mu.
+func (gm *GameManager) availableLocked(e *Entrant) bool {
+	_, online := gm.players[e.Player.ID]
+	return online && !e.Withdrawn && !gm.inGameLocked(e.Player.ID)
+}
+
+// availableEntrantsLocked counts the entrants who could start a game now.
+// Callers must hold gm.mu.
+func (gm *GameManager) availableEntrantsLocked(t *Tournament) int {
+	n := 0
+	for _, e := range t.Entrants {
+		if gm.availableLocked(e) {
+			n++
+		}
+	}
+	return n
+}
+
+// pairSwissLocked pairs the next Swiss round: players with similar scores
+// meet, nobody plays the same opponent twice if it can be helped, and with
+// an odd number the lowest-placed player who hasn't had a bye gets one.
+// Players busy in another game sit the round out. Callers must hold gm.mu.
+func (gm *GameManager) pairSwissLocked(t *Tournament) {
+	scores := t.scores()
+	pool := ratingPool(t.Settings)
+	var players []*Entrant
+	ratings := make(map[*Entrant]float64)
+	for _, e := range t.Entrants {
+		if gm.availableLocked(e) {
+			players = append(players, e)
+			ratings[e] = playerRating(gm.accounts, e.Player.Identity, pool).Rating
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		if scores[players[i]] != scores[players[j]] {
+			return scores[players[i]] > scores[players[j]]
+		}
+		return ratings[players[i]] > ratings[players[j]]
+	})
+
+	if len(players)%2 == 1 {
+		bye := len(players) - 1
+		for i := len(players) - 1; i >= 0; i-- {
+			if !t.hadBye(players[i]) {
+				bye = i
+				break
+			}
+		}
+		t.Games = append(t.Games, &TournamentGame{Round: t.Round, White: players[bye], Bye: true})
+		players = append(players[:bye], players[bye+1:]...)
+	}
+
+	for _, pair := range pairSwiss(players, t.met) {
+		gm.startTournamentGameLocked(t, pair[0], pair[1])
+	}
+}
+
+// pairSwiss pairs players in standings order, each with the highest-placed
+// opponent they haven't met yet, backtracking when that would leave someone
+// without an opponent. If there's no way to avoid rematches, it allows them.
+func pairSwiss(players []*Entrant, met func(a, b *Entrant) bool) [][2]*Entrant {
+	budget := swissPairingBudget
+	var pair func(players []*Entrant, met func(a, b *Entrant) bool) ([][2]*Entrant, bool)
+	pair = func(players []*Entrant, met func(a, b *Entrant) bool) ([][2]*Entrant, bool) {
+		if len(players) == 0 {
+			return nil, true
+		}
+		first := players[0]
+		for i := 1; i < len(players) && budget > 0; i++ {
+			budget--
+			if met(first, players[i]) {
+				continue
+			}
+			rest := make([]*Entrant, 0, len(players)-2)
+			rest = append(rest, players[1:i]...)
+			rest = append(rest, players[i+1:]...)
+			if pairs, ok := pair(rest, met); ok {
+				return append([][2]*Entrant{{first, players[i]}}, pairs...), true
+			}
+		}
+		return nil, false
+	}
+
+	if pairs, ok := pair(players, met); ok {
+		return pairs
+	}
+	pairs, _ := pair(players, func(a, b *Entrant) bool { return false })
+	if pairs == nil {
+		// Out of budget; pair in order
+		for i := 0; i+1 < len(players); i += 2 {
+			pairs = append(pairs, [2]*Entrant{players[i], players[i+1]})
+		}
+	}
+	return pairs
+}
+
+// roundRobinSchedule pairs n players so everyone meets everyone once, using
+// the circle method. With an odd number, -1 marks the player sitting out.
+func roundRobinSchedule(n int) [][][2]int {
+	seats := make([]int, n)
+	for i := range seats {
+		seats[i] = i
+	}
+	if n%2 == 1 {
+		seats = append(seats, -1)
+	}
+
+	size := len(seats)
+	whites := make(map[int]int)
+	var rounds [][][2]int
+	for r := 0; r < size-1; r++ {
+		var pairs [][2]int
+		for i := 0; i < size/2; i++ {
+			white, black := seats[i], seats[size-1-i]
+			// Give White to whoever has had it less so far
+			if whites[black] < whites[white] || (whites[black] == whites[white] && r%2 == 1) {
+				white, black = black, white
+			}
+			if white >= 0 && black >= 0 {
+				whites[white]++
+			}
+			pairs = append(pairs, [2]int{white, black})
+		}
+		rounds = append(rounds, pairs)
+		// Keep the first seat where it is and rotate everyone else
+		seats = append([]int{seats[0], seats[size-1]}, seats[1:size-1]...)
+	}
+	return rounds
+}
+
+// pairRoundRobinLocked starts the scheduled games of the current round.
+// Players who have withdrawn or are busy forfeit. Callers must hold gm.mu.
+func (gm *GameManager) pairRoundRobinLocked(t *Tournament) {
+	for _, pair := range t.schedule[t.Round-1] {
+		if pair[0] < 0 || pair[1] < 0 {
+			sitting := pair[0]
+			if sitting < 0 {
+				sitting = pair[1]
+			}
+			t.Games = append(t.Games, &TournamentGame{Round: t.Round, White: t.Entrants[sitting], Bye: true})
+			continue
+		}
+
+		white, black := t.Entrants[pair[0]], t.Entrants[pair[1]]
+		whiteHere, blackHere := gm.availableLocked(white), gm.availableLocked(black)
+		switch {
+		case whiteHere && blackHere:
+			gm.startTournamentGameLocked(t, white, black)
+		case whiteHere || blackHere:
+			result := &GameResult{Outcome: WhiteWins, Reason: ReasonForfeit}
+			if blackHere {
+				result.Outcome = BlackWins
+			}
+			t.Games = append(t.Games, &TournamentGame{Round: t.Round, White: white, Black: black, Result: result})
+		}
+	}
+}
+
+// pairArenaLocked pairs arena players as soon as they're free, matching
+// those with similar scores and avoiding immediate rematches. Callers must
+// hold gm.mu.
+func (gm *GameManager) pairArenaLocked(t *Tournament, now time.Time) {
+	scores := t.scores()
+	var players []*Entrant
+	for _, e := range t.Entrants {
+		if gm.availableLocked(e) && now.Sub(e.rested) >= arenaRest {
+			players = append(players, e)
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return scores[players[i]] > scores[players[j]]
+	})
+
+	for len(players) >= 2 {
+		first, opponent := players[0], 1
+		for i := 1; i < len(players); i++ {
+			if first.last != players[i] {
+				opponent = i
+				break
+			}
+		}
+		second := players[opponent]
+		players = append(players[1:opponent], players[opponent+1:]...)
+		gm.startTournamentGameLocked(t, first, second)
+	}
+}
+
+// startTournamentGameLocked starts a tournament game between two entrants,
+// giving White to whoever has had it less often. Callers must hold gm.mu.
+func (gm *GameManager) startTournamentGameLocked(t *Tournament, a, b *Entrant) {
+	var white, black *Entrant
+	switch balanceA, balanceB := a.colors[White]-a.colors[Black], b.colors[White]-b.colors[Black]; {
+	case balanceA < balanceB:
+		white, black = a, b
+	case balanceB < balanceA:
+		white, black = b, a
+	default:
+		if w, _ := gm.assignColorsLocked(a.Player, b.Player, ColorRandom); w == a.Player {
+			white, black = a, b
+		} else {
+			white, black = b, a
+		}
+	}
+
+	settings := t.Settings
+	settings.Rated = settings.Rated && canBeRated(white.Player, black.Player)
+	session := gm.startGameLocked(white.Player, black.Player, settings)
+	session.mu.Lock()
+	session.Tournament = t.Name
+	session.mu.Unlock()
+
+	white.colors[White]++
+	black.colors[Black]++
+	white.last, black.last = black, white
+	t.Games = append(t.Games, &TournamentGame{Round: t.Round, White: white, Black: black, Session: session})
+}
+
+// entrant finds the player's entry in the tournament
+func (t *Tournament) entrant(playerID string) *Entrant {
+	for _, e := range t.Entrants {
+		if e.Player.ID == playerID {
+			return e
+		}
+	}
+	return nil
+}
+
+// remaining counts the entrants who haven't withdrawn
+func (t *Tournament) remaining() int {
+	n := 0
+	for _, e := range t.Entrants {
+		if !e.Withdrawn {
+			n++
+		}
+	}
+	return n
+}
+
+// met reports whether two entrants have already been paired
+func (t *Tournament) met(a, b *Entrant) bool {
+	for _, g := range t.Games {
+		if (g.White == a && g.Black == b) || (g.White == b && g.Black == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// hadBye reports whether the entrant has already sat out a round with a bye
+func (t *Tournament) hadBye(e *Entrant) bool {
+	for _, g := range t.Games {
+		if g.Bye && g.White == e {
+			return true
+		}
+	}
+	return false
+}
+
+// points returns what a finished game scored for each side. Arenas give
+// two points for a win and one for a draw; the others one and a half.
+// A Swiss bye is worth a win, while sitting out a round-robin round is
+// worth nothing, since everyone sits out once.
+func (t *Tournament) points(g *TournamentGame) (white, black float64) {
+	win := 1.0
+	if t.Format == FormatArena {
+		win = 2
+	}
+	if g.Bye {
+		if t.Format == FormatSwiss {
+			return win, 0
+		}
+		return 0, 0
+	}
+	if g.Result == nil {
+		return 0, 0
+	}
+	switch g.Result.Outcome {
+	case WhiteWins:
+		return win, 0
+	case BlackWins:
+		return 0, win
+	case Draw:
+		return win / 2, win / 2
+	}
+	return 0, 0
+}
+
+// scores totals each entrant's points
+func (t *Tournament) scores() map[*Entrant]float64 {
+	scores := make(map[*Entrant]float64)
+	for _, g := range t.Games {
+		white, black := t.points(g)
+		scores[g.White] += white
+		if g.Black != nil {
+			scores[g.Black] += black
+		}
+	}
+	return scores
+}
+
+// standings ranks the entrants by score, then Buchholz, then Sonneborn-Berger
+func (t *Tournament) standings() []Standing {
+	scores := t.scores()
+	lines := make(map[*Entrant]*Standing)
+	var standings []*Standing
+	for _, e := range t.Entrants {
+		line := &Standing{PlayerID: e.Player.ID, Name: e.Player.Name, Score: scores[e], Withdrawn: e.Withdrawn}
+		lines[e] = line
+		standings = append(standings, line)
+	}
+
+	for _, g := range t.Games {
+		if g.Bye {
+			continue
+		}
+		if g.Result == nil {
+			lines[g.White].Playing, lines[g.Black].Playing = true, true
+			continue
+		}
+		white, black := t.points(g)
+		for _, side := range []struct {
+			me, opponent *Entrant
+			scored, of   float64
+		}{{g.White, g.Black, white, white + black}, {g.Black, g.White, black, white + black}} {
+			line := lines[side.me]
+			line.Played++
+			line.Buchholz += scores[side.opponent]
+			if side.of > 0 {
+				line.SonnebornBerger += scores[side.opponent] * side.scored / side.of
+			}
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.SonnebornBerger != b.SonnebornBerger {
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		return a.Name < b.Name
+	})
+	result := make([]Standing, len(standings))
+	for i, line := range standings {
+		result[i] = *line
+	}
+	return result
+}
+
+// info snapshots the tournament for display
+func (t *Tournament) info(now time.Time) TournamentInfo {
+	info := TournamentInfo{
+		ID:        t.ID,
+		Name:      t.Name,
+		Format:    t.Format,
+		Settings:  t.Settings,
+		CreatorID: t.Creator.ID,
+		Started:   !t.Started.IsZero(),
+		Finished:  !t.Finished.IsZero(),
+		Standings: t.standings(),
+	}
+
+	switch {
+	case info.Finished:
+		info.Status = "finished"
+		if len(info.Standings) > 0 {
+			info.Status += ", won by " + info.Standings[0].Name
+		}
+	case !info.Started:
+		info.Status = fmt.Sprintf("waiting to start, %d joined", len(t.Entrants))
+	case t.Format == FormatArena:
+		if now.Before(t.Ends) {
+			info.Status = fmt.Sprintf("%s left", formatClock(t.Ends.Sub(now)))
+		} else {
+			info.Status = "finishing the last games"
+		}
+	default:
+		info.Status = fmt.Sprintf("round %d of %d", t.Round, t.Rounds)
+	}
+
+	for _, g := range t.Games {
+		if t.Format == FormatArena && g.done() {
+			continue
+		}
+		if t.Format != FormatArena && g.Round != t.Round {
+			continue
+		}
+		pairing := PairingInfo{White: g.White.Player.Name, Result: "playing"}
+		switch {
+		case g.Bye:
+			pairing.Result = "bye"
+		case g.Result != nil && g.Session == nil:
+			pairing.Black = g.Black.Player.Name
+			pairing.Result = g.Result.Outcome.String() + " forfeit"
+		case g.Result != nil:
+			pairing.Black = g.Black.Player.Name
+			pairing.Result = g.Result.Outcome.String()
+		default:
+			pairing.Black = g.Black.Player.Name
+		}
+		info.Pairings = append(info.Pairings, pairing)
+	}
+	return info
+}
+
+// Tournaments returns snapshots of the open, running and recently finished
+// tournaments, oldest first
+func (gm *GameManager) Tournaments() []TournamentInfo {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	now := time.Now()
+	var tournaments []TournamentInfo
+	for _, t := range gm.tournaments {
+		tournaments = append(tournaments, t.info(now))
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(tournaments[i].ID, "tournament_"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(tournaments[j].ID, "tournament_"))
+		return a < b
+	})
+	return tournaments
+}
+
+// tournamentLine names the tournament a game belongs to
+func (m model) tournamentLine() string {
+	if m.gameSession == nil || m.gameSession.Tournament == "" {
+		return ""
+	}
+	if m.gameState == "finished" && m.endedTournament != m.gameSession.Tournament {
+		return fmt.Sprintf("Tournament: %s. Your next game starts automatically.\n", m.gameSession.Tournament)
+	}
+	return fmt.Sprintf("Tournament: %s\n", m.gameSession.Tournament)
+}
+
+// createTournament opens a tournament from options like "swiss 5 3+2"
+func (m model) createTournament(args []string) model {
+	format, rounds, length, settings, err := ParseTournamentArgs(args)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	t, err := GetGameManager().CreateTournament(m.player, format, rounds, length, settings)
+	if err != nil {
+		m.notice = err.Error()
+		return m
+	}
+	m.notice = ""
+	return m.openTournament(t.ID)
+}
+
+// openTournament shows a tournament's standings
+func (m model) openTournament(id string) model {
+	m.gameState = "tournament"
+	m.tournamentID = id
+	m.notice = ""
+	m.tournaments = GetGameManager().Tournaments()
+	return m
+}
+
+// currentTournament finds the tournament being shown in the latest snapshot
+func (m model) currentTournament() (TournamentInfo, bool) {
+	for _, t := range m.tournaments {
+		if t.ID == m.tournamentID {
+			return t, true
+		}
+	}
+	return TournamentInfo{}, false
+}
+
+// handleTournamentKey joins, withdraws from or starts the tournament being shown
+func (m model) handleTournamentKey(msg tea.KeyMsg) model {
+	var err error
+	switch msg.String() {
+	case "j":
+		err = GetGameManager().JoinTournament(m.tournamentID, m.player)
+	case "w":
+		err = GetGameManager().WithdrawFromTournament(m.tournamentID, m.player.ID)
+	case "s":
+		err = GetGameManager().StartTournament(m.tournamentID, m.player.ID)
+	case "x", "esc":
+		return m.enterLobby()
+	default:
+		return m
+	}
+	if err != nil {
+		m.notice = err.Error()
+	} else {
+		m.notice = ""
+	}
+	m.tournaments = GetGameManager().Tournaments()
+	return m
+}
+
+// tournamentView shows the live standings and pairings
+func (m model) tournamentView() string {
+	var s strings.Builder
+	t, ok := m.currentTournament()
+	if !ok {
+		s.WriteString("CheSSH tournament\n\nThis tournament has been cancelled.\nX to return to the lobby, Q to quit\n")
+		return s.String()
+	}
+
+	s.WriteString(fmt.Sprintf("CheSSH tournament: %s\n", t.Name))
+	s.WriteString(fmt.Sprintf("%s, %s: %s\n", formatTitle(t.Format), t.Settings, t.Status))
+	var keys []string
+	if !t.Finished {
+		keys = append(keys, "J to join", "W to withdraw")
+		if !t.Started && t.CreatorID == m.player.ID {
+			keys = append(keys, "S to start")
+		}
+	}
+	keys = append(keys, "X to return to the lobby", "Q to quit")
+	s.WriteString(strings.Join(keys, ", ") + "\n\n")
+	if m.notice != "" {
+		s.WriteString(m.notice + "\n\n")
+	}
+
+	s.WriteString(fmt.Sprintf("    %-24s %6s %6s %6s %6s\n", "Player", "Score", "Games", "Buch", "S-B"))
+	for i, line := range t.Standings {
+		name := line.Name
+		if line.PlayerID == m.player.ID {
+			name += " (you)"
+		}
+		switch {
+		case line.Withdrawn:
+			name += " (withdrawn)"
+		case line.Playing:
+			name += " *"
+		}
+		s.WriteString(fmt.Sprintf("%3d %-24s %6s %6d %6s %6s\n", i+1, name, formatScore(line.Score), line.Played,
+			formatScore(line.Buchholz), formatScore(math.Round(line.SonnebornBerger*2)/2)))
+	}
+
+	if len(t.Pairings) > 0 {
+		if t.Format == FormatArena {
+			s.WriteString("\nGames in progress\n")
+		} else {
+			s.WriteString("\nThis round\n")
+		}
+		for _, p := range t.Pairings {
+			if p.Result == "bye" {
+				s.WriteString(fmt.Sprintf("  %s has a bye\n", p.White))
+				continue
+			}
+			s.WriteString(fmt.Sprintf("  %s vs %s: %s\n", p.White, p.Black, p.Result))
+		}
+	}
+	return s.String()
+}
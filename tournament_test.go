@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// connectTestPlayers connects guests named a, b, c… to gm
+func connectTestPlayers(gm *GameManager, n int) []*Player {
+	var players []*Player
+	for i := range n {
+		name := string(rune('a' + i))
+		p := &Player{ID: fmt.Sprintf("player_%s", name), Name: name, Connected: true, UpdateChan: make(chan GameUpdate, 100)}
+		gm.Connect(p)
+		players = append(players, p)
+	}
+	return players
+}
+
+// startTestTournament creates a tournament for the players and starts it
+func startTestTournament(t *testing.T, gm *GameManager, format string, rounds int, players []*Player) *Tournament {
+	t.Helper()
+	tournament, err := gm.CreateTournament(players[0], format, rounds, time.Minute, GameSettings{TimeControl: DefaultTimeControl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range players[1:] {
+		if err := gm.JoinTournament(tournament.ID, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gm.StartTournament(tournament.ID, players[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	return tournament
+}
+
+func TestTournamentOverAfterEntrantDisconnects(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 3)
+	tournament := startTestTournament(t, gm, FormatSwiss, 1, players)
+
+	var game, bye *TournamentGame
+	for _, g := range tournament.Games {
+		if g.Bye {
+			bye = g
+		} else {
+			game = g
+		}
+	}
+	if game == nil || bye == nil {
+		t.Fatalf("round 1 of a 3 player Swiss has %d games, want a game and a bye", len(tournament.Games))
+	}
+
+	// The player with the bye leaves, and their session closes their channel
+	gone := bye.White.Player
+	gm.RemovePlayer(gone.ID)
+	close(gone.UpdateChan)
+
+	if err := game.Session.Resign(game.White.Player.ID); err != nil {
+		t.Fatal(err)
+	}
+	gm.mu.Lock()
+	gm.runTournamentsLocked(time.Now())
+	gm.mu.Unlock()
+
+	if tournament.Finished.IsZero() {
+		t.Fatal("the tournament didn't finish after its only round")
+	}
+}
+
+func TestIdleSwissWaitsForPlayers(t *testing.T) {
+	gm := newGameManager()
+	players := connectTestPlayers(gm, 3)
+	tournament := startTestTournament(t, gm, FormatSwiss, 3, players)
+
+	var game *TournamentGame
+	for _, g := range tournament.Games {
+		if !g.Bye {
+			game = g
+		}
+	}
+	if err := game.Session.Resign(game.White.Player.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The round's players go straight into a casual game, leaving one free
+	gm.mu.Lock()
+	casual := gm.startGameLocked(game.White.Player, game.Black.Player, GameSettings{TimeControl: DefaultTimeControl})
+	for range 5 {
+		gm.runTournamentsLocked(time.Now())
+	}
+	round, finished := tournament.Round, !tournament.Finished.IsZero()
+	gm.mu.Unlock()
+	if round != 1 || finished {
+		t.Fatalf("with one player free, the tournament is in round %d (finished %v), want it waiting in round 1", round, finished)
+	}
+
+	if err := casual.Resign(game.White.Player.ID); err != nil {
+		t.Fatal(err)
+	}
+	gm.mu.Lock()
+	gm.runTournamentsLocked(time.Now())
+	round = tournament.Round
+	gm.mu.Unlock()
+	if round != 2 {
+		t.Fatalf("once the players are free, the tournament is in round %d, want 2", round)
+	}
+}
+
+// testEntrants makes entrants named a, b, c…
+func testEntrants(n int) []*Entrant {
+	var entrants []*Entrant
+	for i := range n {
+		name := string(rune('a' + i))
+		entrants = append(entrants, &Entrant{Player: &Player{ID: "player_" + name, Name: name}})
+	}
+	return entrants
+}
+
+func TestPairSwiss(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		n    int
+		met  [][2]int
+		want [][2]int
+	}{{
+		name: "first round",
+		n:    4,
+		want: [][2]int{{0, 1}, {2, 3}},
+	}, {
+		name: "avoids a rematch",
+		n:    4,
+		met:  [][2]int{{0, 1}, {2, 3}},
+		want: [][2]int{{0, 2}, {1, 3}},
+	}, {
+		name: "backtracks so the last pair isn't a rematch",
+		n:    4,
+		met:  [][2]int{{0, 1}, {1, 3}},
+		want: [][2]int{{0, 3}, {1, 2}},
+	}, {
+		name: "allows rematches when everyone has met",
+		n:    2,
+		met:  [][2]int{{0, 1}},
+		want: [][2]int{{0, 1}},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			entrants := testEntrants(tt.n)
+			met := func(a, b *Entrant) bool {
+				for _, m := range tt.met {
+					x, y := entrants[m[0]], entrants[m[1]]
+					if (a == x && b == y) || (a == y && b == x) {
+						return true
+					}
+				}
+				return false
+			}
+			var got [][2]string
+			for _, pair := range pairSwiss(entrants, met) {
+				got = append(got, [2]string{pair[0].Player.Name, pair[1].Player.Name})
+			}
+			var want [][2]string
+			for _, pair := range tt.want {
+				want = append(want, [2]string{entrants[pair[0]].Player.Name, entrants[pair[1]].Player.Name})
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("pairSwiss = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRoundRobinSchedule(t *testing.T) {
+	for n := 2; n <= 7; n++ {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			rounds := roundRobinSchedule(n)
+			if want := n - 1 + n%2; len(rounds) != want {
+				t.Fatalf("%d rounds, want %d", len(rounds), want)
+			}
+			met := make(map[[2]int]int)
+			byes := make(map[int]int)
+			for r, pairs := range rounds {
+				seen := make(map[int]bool)
+				for _, pair := range pairs {
+					for _, p := range pair {
+						if p >= 0 && seen[p] {
+							t.Errorf("round %d has player %d twice", r+1, p)
+						}
+						seen[p] = true
+					}
+					switch {
+					case pair[0] < 0:
+						byes[pair[1]]++
+					case pair[1] < 0:
+						byes[pair[0]]++
+					default:
+						met[[2]int{min(pair[0], pair[1]), max(pair[0], pair[1])}]++
+					}
+				}
+			}
+			for a := range n {
+				for b := a + 1; b < n; b++ {
+					if met[[2]int{a, b}] != 1 {
+						t.Errorf("players %d and %d meet %d times, want once", a, b, met[[2]int{a, b}])
+					}
+				}
+				if want := n % 2; byes[a] != want {
+					t.Errorf("player %d sits out %d times, want %d", a, byes[a], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStandings(t *testing.T) {
+	e := testEntrants(5)
+	a, b, c, d, x := e[0], e[1], e[2], e[3], e[4]
+	result := func(outcome Outcome) *GameResult { return &GameResult{Outcome: outcome} }
+	tournament := &Tournament{Format: FormatSwiss, Entrants: e, Games: []*TournamentGame{
+		{Round: 1, White: a, Black: b, Result: result(WhiteWins)},
+		{Round: 1, White: c, Black: d, Result: result(Draw)},
+		{Round: 1, White: x, Bye: true},
+		{Round: 2, White: c, Black: a, Result: result(Draw)},
+		{Round: 2, White: d, Black: b, Result: result(BlackWins)},
+		{Round: 3, White: x, Black: b},
+	}}
+
+	want := []Standing{
+		{Name: "a", Score: 1.5, Buchholz: 2, SonnebornBerger: 1.5, Played: 2},
+		{Name: "c", Score: 1, Buchholz: 2, SonnebornBerger: 1, Played: 2},
+		{Name: "b", Score: 1, Buchholz: 2, SonnebornBerger: 0.5, Played: 2, Playing: true},
+		{Name: "e", Score: 1, Playing: true},
+		{Name: "d", Score: 0.5, Buchholz: 2, SonnebornBerger: 0.5, Played: 2},
+	}
+	got := tournament.standings()
+	if len(got) != len(want) {
+		t.Fatalf("%d standings, want %d", len(got), len(want))
+	}
+	for i, line := range got {
+		line.PlayerID = ""
+		if line != want[i] {
+			t.Errorf("place %d = %+v, want %+v", i+1, line, want[i])
+		}
+	}
+}